@@ -1,56 +1,219 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 	"wbl0/internal/broker"
 	"wbl0/internal/cache"
+	"wbl0/internal/config"
 	"wbl0/internal/database"
+	"wbl0/internal/database/migrations"
+	"wbl0/internal/logging"
+	"wbl0/internal/model"
 	"wbl0/internal/server"
 	"wbl0/internal/service"
 )
 
 func main() {
-	//Добавление горутины в WaitGroup
-	var wg sync.WaitGroup
-	wg.Add(1)
+	//Загрузка конфигурации из файла, переменных окружения и флагов командной строки.
+	//После флагов допускается только подкоманда migrate с ее параметрами
+	args := os.Args[1:]
+	cfg, rest, err := config.Parse(args)
+	if err == nil && len(rest) > 0 && rest[0] != "migrate" {
+		err = fmt.Errorf("неизвестная подкоманда %q, ожидается migrate", rest[0])
+	}
+	if err != nil {
+		//Журнал из конфигурации еще не создан
+		fatal(slog.Default(), "Ошибка при загрузке конфигурации", err)
+	}
+
+	//Подкоманда migrate только применяет или откатывает миграции схемы БД, сервис при этом не запускается.
+	//Флаги могут быть указаны как до подкоманды, так и после нее
+	if len(rest) > 0 {
+		n := len(args) - len(rest)
+		if err := runMigrate(append(args[:n:n], rest[1:]...), os.Stdout); err != nil {
+			fatal(slog.Default(), "Ошибка при выполнении миграций", err)
+		}
+		return
+	}
+
+	//Журнал в формате и с уровнем из конфигурации. Он же используется как журнал по умолчанию
+	logger := logging.New(cfg.Log, os.Stdout)
+	slog.SetDefault(logger)
+
+	//Политики строковых полей заказов из конфигурации поверх умолчаний
+	if err := model.SetFieldPolicies(cfg.Validation.FieldPolicies()); err != nil {
+		fatal(logger, "Ошибка в политиках полей заказа", err)
+	}
+
+	//Контекст, который отменяется при получении SIGINT или SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	//Инициализация БД postgres
-	db := database.InitDB("localhost", "5432", "postgres", "postgres", "wbl0")
-	defer db.Close()
+	db, err := database.InitDB(cfg.Postgres)
+	if err != nil {
+		fatal(logger, "Ошибка при подключении к базе данных", err)
+	}
 
-	//Создание таблиц в БД, если они еще не созданы
-	if err := database.CreateTables(db); err != nil {
-		log.Fatal("Ошибка при создании таблиц в базе данных")
+	//Применение еще не примененных миграций схемы БД
+	applied, err := migrations.Up(db)
+	if err != nil {
+		fatal(logger, "Ошибка при применении миграций базы данных", err)
 	}
+	logger.Info("Схема базы данных актуальна", "applied_migrations", applied)
 
 	//Инициализация кэша
-	cache := cache.NewCache()
+	cacheOpts := []cache.Option{
+		cache.WithMaxEntries(cfg.Cache.MaxEntries),
+		cache.WithMaxBytes(cfg.Cache.MaxBytes),
+		cache.WithTTL(cfg.Cache.TTL),
+	}
+	var orderCache cache.OrderCache = cache.NewCache(cacheOpts...)
+	if cfg.Cache.Shards > 0 {
+		orderCache = cache.NewShardedCache(cfg.Cache.Shards, cacheOpts...)
+	}
+
+	//Счетчики кэша отдаются в /metrics вместе с метриками брокера, БД и HTTP-сервера
+	prometheus.MustRegister(cache.NewCollector(orderCache))
 
 	//Создание сервиса для работы с БД
-	dataService := service.NewService(database.NewDB(db), cache)
+	pgDB := database.NewDB(db,
+		database.WithDuplicatePolicy(database.DuplicatePolicy(cfg.Postgres.DuplicatePolicy)),
+		database.WithLogger(logger),
+	)
+	dataService := service.NewService(pgDB, orderCache, service.WithLogger(logger))
 
-	//При старте сервиса восстанавливаются данные из БД в кэш.
-	data, err := dataService.GetAllDataFromDB()
-	if err != nil {
-		log.Fatal("Ошибка при чтении данных из базы данных:", err)
+	//Инициализация NATS
+	broker.SetLogger(logger)
+	broker.SetStrictSchema(cfg.Validation.StrictSchema)
+	if err := broker.InitNATS(cfg.NATS); err != nil {
+		fatal(logger, "Ошибка при подключении к NATS", err)
 	}
 
-	for _, order := range data {
-		cache.SetById(order.OrderUid, order)
+	//Хранилище отклоненных сообщений для просмотра и повторной отправки через HTTP
+	deadLetters := broker.NewDeadLetterStore(cfg.NATS.DeadLetterCapacity)
+	if _, err := broker.SubscribeToDeadLetters(deadLetters); err != nil {
+		fatal(logger, "Ошибка при подписке на канал недоставленных сообщений", err)
 	}
 
-	//Инициализация NATS
-	broker.InitNATS()
+	//HTTP-сервер запускается до загрузки кэша, чтобы /healthz отвечал сразу,
+	//а /readyz сообщал о неготовности, пока кэш не загружен, БД или NATS недоступны
+	var cacheReady atomic.Bool
+	httpServer := server.NewServer(cfg.HTTP, server.NewHTTPServer(dataService,
+		server.WithDeadLetters(deadLetters),
+		server.WithLogger(logger),
+		server.WithReadinessCheck("cache", func(context.Context) error {
+			if !cacheReady.Load() {
+				return errors.New("кэш еще не загружен")
+			}
+			return nil
+		}),
+		server.WithReadinessCheck("postgres", db.PingContext),
+		server.WithReadinessCheck("nats", broker.CheckConnection),
+	))
+	go func() {
+		logger.Info("Сервер запущен", "addr", cfg.HTTP.Addr)
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "Ошибка HTTP-сервера", err)
+		}
+	}()
+
+	//При старте сервиса восстанавливаются данные из снимка и БД в кэш.
+	if err := warmUpCache(logger, cfg.Cache, dataService, orderCache); err != nil {
+		fatal(logger, "Ошибка при чтении данных из базы данных", err)
+	}
+	cacheReady.Store(true)
+	if cfg.Cache.SnapshotPath != "" {
+		go cache.RunSnapshots(ctx, orderCache, cfg.Cache.SnapshotPath, cfg.Cache.SnapshotInterval, dataService.Watermark, logger)
+	}
 
 	//Подписка на канал NATS для получения данных
-	broker.SubscribeToNATS(dataService, &wg)
+	subscribeOrders, subscribeStatuses := broker.SubscribeToNATS, broker.SubscribeToStatusUpdates
+	if cfg.NATS.JetStream {
+		if err := broker.InitJetStream(); err != nil {
+			fatal(logger, "Ошибка при инициализации JetStream", err)
+		}
+		subscribeOrders, subscribeStatuses = broker.SubscribeToJetStream, broker.SubscribeToStatusUpdatesJetStream
+	}
+	if _, err := subscribeOrders(dataService); err != nil {
+		fatal(logger, "Ошибка при подписке на канал заказов", err)
+	}
+	if _, err := subscribeStatuses(dataService); err != nil {
+		fatal(logger, "Ошибка при подписке на канал изменения статусов", err)
+	}
+
+	<-ctx.Done()
+	stop()
+	logger.Info("Получен сигнал остановки, завершение работы")
+	shutdown(logger, cfg, httpServer, db, dataService, orderCache)
+}
+
+// Запись ошибки запуска в журнал и завершение работы
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// Восстановление данных в кэше при старте сервиса.
+// Если задан файл снимка и он успешно загружен, из БД дочитываются только заказы, измененные не раньше водяного знака снимка.
+// Иначе из БД загружаются все заказы.
+func warmUpCache(logger *slog.Logger, cfg config.Cache, s *service.Service, c cache.OrderCache) error {
+	setById := func(order model.OrderInfo) error {
+		c.SetById(order.OrderUid, order)
+		return nil
+	}
+
+	watermark, err := time.Time{}, errors.New("снимки кэша отключены")
+	if cfg.SnapshotPath != "" {
+		watermark, err = cache.LoadSnapshot(c, cfg.SnapshotPath)
+	}
+	if err == nil {
+		logger.Info("Кэш восстановлен из снимка, загрузка заказов начиная с watermark", "watermark", watermark)
+		return s.ForEachOrderUpdatedAfter(watermark, setById)
+	}
+
+	if cfg.SnapshotPath != "" && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Снимок кэша не загружен", "error", err)
+	}
+	return s.ForEachOrder(setById)
+}
 
-	//Обработчик http для корневого пути
-	http.Handle("/", server.NewHTTPServer(dataService))
-	addr := ":8080"
-	fmt.Println("Сервер запущен и слушает на", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+// Плавная остановка сервиса: сначала перестают приниматься HTTP-запросы,
+// затем останавливаются подписки NATS с ожиданием сохранения уже полученных заказов, после чего закрывается БД.
+// Перед закрытием БД сохраняется снимок кэша, чтобы следующий запуск не читал все заказы из БД.
+// Все этапы должны уложиться в cfg.ShutdownTimeout.
+func shutdown(logger *slog.Logger, cfg config.Config, httpServer *http.Server, db *sql.DB, s *service.Service, c cache.OrderCache) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Error("Ошибка при остановке HTTP-сервера", "error", err)
+	}
+	if err := broker.Drain(ctx); err != nil {
+		logger.Error("Ошибка при остановке подписок NATS", "error", err)
+	}
+	if cfg.Cache.SnapshotPath != "" {
+		watermark, err := s.Watermark()
+		if err == nil {
+			err = cache.SaveSnapshot(c, cfg.Cache.SnapshotPath, watermark)
+		}
+		if err != nil {
+			logger.Error("Ошибка при сохранении снимка кэша", "error", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		logger.Error("Ошибка при закрытии подключения к базе данных", "error", err)
+	}
+	logger.Info("Сервис остановлен")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"wbl0/internal/config"
+	"wbl0/internal/database"
+	"wbl0/internal/database/migrations"
+)
+
+// Подкоманда управления схемой БД: migrate [флаги] up | down [n] | status.
+// Флаги те же, что у сервиса; down без n откатывает одну последнюю миграцию.
+func runMigrate(args []string, out io.Writer) error {
+	cfg, rest, err := config.Parse(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("не указано действие: up, down [n] или status")
+	}
+
+	db, err := database.InitDB(cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action := rest[0]; {
+	case action == "up" && len(rest) == 1:
+		applied, err := migrations.Up(db)
+		fmt.Fprintf(out, "Применено миграций: %d\n", applied)
+		return err
+	case action == "down" && len(rest) <= 2:
+		steps := 1
+		if len(rest) == 2 {
+			steps, err = strconv.Atoi(rest[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("некорректное количество откатываемых миграций %q", rest[1])
+			}
+		}
+		rolledBack, err := migrations.Down(db, steps)
+		fmt.Fprintf(out, "Откачено миграций: %d\n", rolledBack)
+		return err
+	case action == "status" && len(rest) == 1:
+		statuses, err := migrations.Status(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "не применена"
+			if s.Applied() {
+				state = "применена " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестное действие %q, ожидается up, down [n] или status", action)
+	}
+}
@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"wbl0/internal/database"
+	"wbl0/internal/model"
+)
+
+// Проверка того, что сообщение содержит пакет заказов: NDJSON или JSON-массив
+func isBatch(payload []byte, contentType string) bool {
+	mt, err := mediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case ContentTypeNDJSON:
+		return true
+	case ContentTypeJSON:
+		trimmed := bytes.TrimLeft(payload, " \t\r\n")
+		return len(trimmed) > 0 && trimmed[0] == '['
+	}
+	return false
+}
+
+// Разбиение пакета на заказы в формате JSON. Пустые строки NDJSON пропускаются
+func splitBatch(payload []byte, contentType string) ([][]byte, error) {
+	var elements [][]byte
+	if mt, _ := mediaType(contentType); mt == ContentTypeNDJSON {
+		for _, line := range bytes.Split(payload, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				elements = append(elements, line)
+			}
+		}
+	} else {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			return nil, err
+		}
+		for _, element := range raw {
+			elements = append(elements, element)
+		}
+	}
+
+	if len(elements) == 0 {
+		return nil, errors.New("пакет не содержит заказов")
+	}
+	return elements, nil
+}
+
+// Отклоненный заказ пакета: номер в пакете и ошибка
+type batchRejection struct {
+	index int
+	oErr  *orderError
+}
+
+// Разбор, валидация и сохранение пакета заказов из тела сообщения payload.
+// Каждый заказ пакета разбирается и валидируется отдельно, прошедшие проверку заказы сохраняются через s.SaveBatch одной транзакцией.
+// Источник каждого заказа - src с хэшем самого заказа.
+// Отклоненные заказы, в том числе не сохраненные из-за ошибки в самом заказе, отправляются в канал недоставленных сообщений
+// по одному в формате JSON после сохранения пакета, поэтому при повторной доставке пакета они не дублируются.
+// *orderError возвращается, только если пакет не удалось разобрать или сохранить целиком,
+// в том числе если заказ не сохранен из-за ошибки, которая может не повториться при повторной доставке.
+func processBatch(ctx context.Context, s OrderSaver, payload []byte, contentType string, src model.Source) error {
+	elements, err := splitBatch(payload, contentType)
+	if err != nil {
+		messagesReceived.Inc()
+		logger.WarnContext(ctx, "Ошибка при разборе пакета заказов", "error", err)
+		oErr := &orderError{stage: stageDecode, err: err}
+		observeRejected(oErr)
+		publishDeadLetter(ctx, OrderSubject, payload, contentType, oErr, src.ReceivedAt)
+		return oErr
+	}
+	logger.InfoContext(ctx, "Получен пакет заказов из NATS", "orders", len(elements))
+	messagesReceived.Add(float64(len(elements)))
+
+	var orders []model.OrderInfo
+	var srcs []model.Source
+	var indexes []int
+	var rejected []batchRejection
+	for i, element := range elements {
+		order, oErr := decodeOrder(element, ContentTypeJSON)
+		if oErr == nil {
+			if err := order.Validate(); err != nil {
+				oErr = &orderError{stage: stageValidate, err: err}
+			}
+		}
+		if oErr != nil {
+			rejected = append(rejected, batchRejection{index: i, oErr: oErr})
+			continue
+		}
+		orders = append(orders, order)
+		orderSrc := src
+		orderSrc.PayloadHash = payloadHash(element)
+		srcs = append(srcs, orderSrc)
+		indexes = append(indexes, i)
+	}
+
+	if len(orders) > 0 {
+		errs, err := s.SaveBatch(ctx, orders, srcs)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при сохранении пакета заказов", "orders", len(orders), "error", err)
+			oErr := &orderError{stage: stageSave, err: err}
+			observeRejected(oErr)
+			return oErr
+		}
+
+		for j, err := range errs {
+			switch {
+			case err == nil:
+				messagesSaved.Inc()
+			case errors.Is(err, database.ErrDuplicate):
+				rejected = append(rejected, batchRejection{index: indexes[j], oErr: &orderError{stage: stageDuplicate, err: err}})
+			default:
+				rejected = append(rejected, batchRejection{index: indexes[j], oErr: &orderError{stage: stageSave, err: err}})
+			}
+		}
+	}
+
+	for _, r := range rejected {
+		logger.WarnContext(ctx, "Заказ из пакета отклонен", "index", r.index, "stage", r.oErr.stage, "error", r.oErr.err)
+		observeRejected(r.oErr)
+		publishDeadLetter(ctx, OrderSubject, elements[r.index], ContentTypeJSON, r.oErr, src.ReceivedAt)
+	}
+	logger.InfoContext(ctx, "Пакет заказов обработан", "orders", len(elements), "saved", len(elements)-len(rejected), "rejected", len(rejected))
+	return nil
+}
@@ -0,0 +1,98 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"wbl0/internal/model"
+	"wbl0/testutils"
+)
+
+func TestIsBatch(t *testing.T) {
+	assert.True(t, isBatch([]byte(" [{}]"), ""))
+	assert.True(t, isBatch([]byte("[]"), ContentTypeJSON))
+	assert.True(t, isBatch([]byte("{}\n{}"), ContentTypeNDJSON))
+	assert.False(t, isBatch([]byte("{}"), ""))
+	assert.False(t, isBatch([]byte("["), ContentTypeProtobuf))
+}
+
+func TestProcessOrder_Batch(t *testing.T) {
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+	require.NoError(t, Nconn.Flush())
+
+	second := testutils.TestOrder
+	second.OrderUid = "second"
+	invalid := testutils.TestOrder
+	invalid.Payment.Amount++
+	var elements [][]byte
+	for _, order := range []model.OrderInfo{testutils.TestOrder, invalid, second} {
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+		elements = append(elements, data)
+	}
+	array := append(append([]byte("["), bytes.Join(elements, []byte(","))...), ']')
+
+	//Ошибка сохранения пакета возвращается целиком, отклоненные заказы не отправляются в канал недоставленных сообщений
+	var oErr *orderError
+	require.ErrorAs(t, processOrder(&fakeSaver{batchErr: errors.New("база данных недоступна")}, &nats.Msg{Subject: OrderSubject, Data: array}), &oErr)
+	assert.Equal(t, stageSave, oErr.stage)
+
+	//Ошибка сохранения отдельного заказа, которая может не повториться, возвращается для повторной доставки пакета
+	require.ErrorAs(t, processOrder(&fakeSaver{failures: 1}, &nats.Msg{Subject: OrderSubject, Data: array}), &oErr)
+	assert.Equal(t, stageSave, oErr.stage)
+
+	//Заказы JSON-массива сохраняются с хэшем каждого заказа, а некорректный заказ отправляется в канал недоставленных сообщений отдельно
+	received := testutil.ToFloat64(messagesReceived)
+	saver := &fakeSaver{}
+	require.NoError(t, processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: array}))
+	_, saved := saver.state()
+	assert.Equal(t, []model.OrderInfo{testutils.TestOrder, second}, saved)
+	assert.Equal(t, payloadHash(elements[0]), saver.srcs[0].PayloadHash)
+	assert.Equal(t, payloadHash(elements[2]), saver.srcs[1].PayloadHash)
+	assert.Equal(t, received+3, testutil.ToFloat64(messagesReceived))
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	letter := store.List()[0]
+	assert.Equal(t, stageValidate, letter.Stage)
+	assert.Equal(t, ContentTypeJSON, letter.ContentType)
+	assert.JSONEq(t, string(elements[1]), letter.Payload)
+
+	//NDJSON: строка с некорректным JSON отклоняется, остальные заказы сохраняются
+	msg := nats.NewMsg(OrderSubject)
+	msg.Header.Set(HeaderContentType, ContentTypeNDJSON)
+	msg.Data = bytes.Join([][]byte{elements[0], []byte("{not json"), {}, elements[2]}, []byte("\n"))
+	saver = &fakeSaver{}
+	require.NoError(t, processOrder(saver, msg))
+	_, saved = saver.state()
+	assert.Equal(t, []model.OrderInfo{testutils.TestOrder, second}, saved)
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, stageDecode, store.List()[1].Stage)
+
+	//Повторные заказы пакета отклоняются по одному
+	require.NoError(t, processOrder(&fakeSaver{duplicate: true}, msg))
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, letter := range store.List()[3:] {
+		assert.Equal(t, stageDuplicate, letter.Stage)
+	}
+
+	//Пустой пакет не удается разобрать
+	require.ErrorAs(t, processOrder(&fakeSaver{}, &nats.Msg{Subject: OrderSubject, Data: []byte("[]")}), &oErr)
+	assert.Equal(t, stageDecode, oErr.stage)
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"mime"
+	"wbl0/internal/model"
+	"wbl0/internal/model/orderpb"
+)
+
+// Заголовок с форматом тела сообщения с заказом. Сообщения без заголовка считаются JSON
+const HeaderContentType = "Content-Type"
+
+// Поддерживаемые форматы сообщений с заказом
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	//Пакет заказов: по одному заказу в формате JSON на строку. Пакет также можно передать JSON-массивом заказов
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// Разбор заказа из тела сообщения в формате, заданном заголовком Content-Type.
+// JSON перед разбором проверяется по JSON Schema заказа, protobuf в строгом режиме проверяется на неизвестные поля.
+func decodeOrder(payload []byte, contentType string) (model.OrderInfo, *orderError) {
+	mt, err := mediaType(contentType)
+	if err != nil {
+		return model.OrderInfo{}, &orderError{stage: stageDecode, err: err}
+	}
+
+	switch mt {
+	case ContentTypeJSON:
+		//Исходное сообщение проверяется по JSON Schema до разбора, чтобы неизвестные и пропущенные поля не терялись при декодировании
+		if err := validateSchema(payload); err != nil {
+			if !json.Valid(payload) {
+				return model.OrderInfo{}, &orderError{stage: stageDecode, err: err}
+			}
+			return model.OrderInfo{}, &orderError{stage: stageSchema, err: err}
+		}
+		var order model.OrderInfo
+		if err := json.Unmarshal(payload, &order); err != nil {
+			return model.OrderInfo{}, &orderError{stage: stageDecode, err: err}
+		}
+		return order, nil
+	case ContentTypeProtobuf:
+		var m orderpb.OrderInfo
+		if err := proto.Unmarshal(payload, &m); err != nil {
+			return model.OrderInfo{}, &orderError{stage: stageDecode, err: err}
+		}
+		if strictSchema {
+			if errs := unknownFields(m.ProtoReflect(), ""); len(errs) > 0 {
+				return model.OrderInfo{}, &orderError{stage: stageSchema, err: errs}
+			}
+		}
+		return model.OrderFromProto(&m), nil
+	}
+	return model.OrderInfo{}, &orderError{stage: stageDecode, err: fmt.Errorf("неподдерживаемый формат сообщения %q", mt)}
+}
+
+// Формат тела сообщения из заголовка Content-Type без параметров. Пустой заголовок означает JSON,
+// application/protobuf считается синонимом application/x-protobuf
+func mediaType(contentType string) (string, error) {
+	if contentType == "" {
+		return ContentTypeJSON, nil
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("некорректный заголовок %s: %w", HeaderContentType, err)
+	}
+	if mt == "application/protobuf" {
+		return ContentTypeProtobuf, nil
+	}
+	return mt, nil
+}
+
+// Проверка того, что сообщение передано в формате protobuf
+func isProtobuf(contentType string) bool {
+	mt, err := mediaType(contentType)
+	return err == nil && mt == ContentTypeProtobuf
+}
+
+// Поиск сообщений protobuf с полями, которых нет в order.proto. path - путь к сообщению m
+func unknownFields(m protoreflect.Message, path string) model.ValidationErrors {
+	var errs model.ValidationErrors
+	if len(m.GetUnknown()) > 0 {
+		errs = append(errs, model.FieldError{Field: path, Rule: "schema", Message: "неизвестные поля protobuf"})
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+		name := string(fd.Name())
+		if path != "" {
+			name = path + "." + name
+		}
+		if fd.IsList() {
+			for i := 0; i < v.List().Len(); i++ {
+				errs = append(errs, unknownFields(v.List().Get(i).Message(), fmt.Sprintf("%s[%d]", name, i))...)
+			}
+		} else {
+			errs = append(errs, unknownFields(v.Message(), name)...)
+		}
+		return true
+	})
+	return errs
+}
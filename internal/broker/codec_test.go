@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"encoding/base64"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+	"wbl0/testutils"
+)
+
+// Вспомогательная функция для создания сообщения с заказом в формате protobuf
+func protobufMsg(t *testing.T, payload []byte) *nats.Msg {
+	msg := nats.NewMsg(OrderSubject)
+	msg.Header.Set(HeaderContentType, ContentTypeProtobuf)
+	msg.Data = payload
+	return msg
+}
+
+func TestProcessOrder_Protobuf(t *testing.T) {
+	initTestNATS(t)
+	t.Cleanup(func() { SetStrictSchema(false) })
+
+	payload, err := proto.Marshal(testutils.TestOrder.ToProto())
+	require.NoError(t, err)
+
+	//Заказ в формате protobuf преобразуется в модель и сохраняется
+	saver := &fakeSaver{}
+	require.NoError(t, processOrder(saver, protobufMsg(t, payload)))
+	require.Len(t, saver.saved, 1)
+	assert.Equal(t, testutils.TestOrder, saver.saved[0])
+
+	//Синоним типа содержимого с параметрами
+	msg := protobufMsg(t, payload)
+	msg.Header.Set(HeaderContentType, "application/protobuf; proto=wbl0.order.v1.OrderInfo")
+	assert.NoError(t, processOrder(saver, msg))
+
+	//Неизвестное поле отклоняется только в строгом режиме
+	withUnknown := protowire.AppendVarint(protowire.AppendTag(append([]byte(nil), payload...), 99, protowire.VarintType), 1)
+	assert.NoError(t, processOrder(saver, protobufMsg(t, withUnknown)))
+	SetStrictSchema(true)
+	var oErr *orderError
+	require.ErrorAs(t, processOrder(saver, protobufMsg(t, withUnknown)), &oErr)
+	assert.Equal(t, stageSchema, oErr.stage)
+
+	//Некорректное содержимое и неподдерживаемый формат отклоняются на этапе разбора
+	require.ErrorAs(t, processOrder(saver, protobufMsg(t, []byte{0xff})), &oErr)
+	assert.Equal(t, stageDecode, oErr.stage)
+	msg = nats.NewMsg(OrderSubject)
+	msg.Header.Set(HeaderContentType, "application/xml")
+	msg.Data = []byte("<order/>")
+	require.ErrorAs(t, processOrder(saver, msg), &oErr)
+	assert.Equal(t, stageDecode, oErr.stage)
+}
+
+func TestDeadLetters_ReplayKeepsContentType(t *testing.T) {
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+	require.NoError(t, Nconn.Flush())
+
+	//Заказ в формате protobuf, не прошедший валидацию
+	order := testutils.TestOrder
+	order.Payment.Amount++
+	payload, err := proto.Marshal(order.ToProto())
+	require.NoError(t, err)
+	assert.Error(t, processOrder(&fakeSaver{}, protobufMsg(t, payload)))
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	letter := store.List()[0]
+	assert.Equal(t, ContentTypeProtobuf, letter.ContentType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(payload), letter.Payload)
+
+	//Повторная отправка сохраняет тип содержимого
+	orders, err := Nconn.SubscribeSync(OrderSubject)
+	require.NoError(t, err)
+	require.NoError(t, store.Replay(letter.ID))
+	msg, err := orders.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeProtobuf, msg.Header.Get(HeaderContentType))
+	assert.Equal(t, payload, msg.Data)
+}
@@ -0,0 +1,170 @@
+package broker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"sync"
+	"time"
+	"wbl0/internal/logging"
+	"wbl0/internal/model"
+)
+
+// Канал для сообщений, которые не удалось разобрать или провалидировать
+var DeadLetterSubject = "order_info.dlq"
+
+// Заголовки, которые добавляются к сообщению при отправке в канал недоставленных сообщений
+const (
+	HeaderFailureStage = "Wbl0-Failure-Stage"
+	HeaderFailureError = "Wbl0-Failure-Error"
+	//Список ошибок валидации полей в формате JSON, если сообщение отклонено при валидации
+	HeaderFailureFields = "Wbl0-Failure-Fields"
+	HeaderReceivedAt    = "Wbl0-Received-At"
+	//Канал, из которого получено сообщение. В него сообщение публикуется при повторной отправке
+	HeaderSubject = "Wbl0-Subject"
+)
+
+// Ошибка при попытке повторить отсутствующее в хранилище сообщение
+var ErrDeadLetterNotFound = errors.New("сообщение не найдено")
+
+// Отклоненное сообщение с исходным содержимым и причиной отклонения
+type DeadLetter struct {
+	ID      uint64 `json:"id"`
+	Subject string `json:"subject"`
+	Stage   string `json:"stage"`
+	Error   string `json:"error"`
+	//Ошибки валидации по полям заказа
+	Fields     model.ValidationErrors `json:"fields,omitempty"`
+	ReceivedAt time.Time              `json:"received_at"`
+	//Идентификатор корреляции исходного сообщения, сохраняется при повторной отправке
+	CorrelationID string `json:"correlation_id,omitempty"`
+	//Тип содержимого исходного сообщения, сохраняется при повторной отправке
+	ContentType string `json:"content_type,omitempty"`
+	//Исходное содержимое. Сообщения в формате protobuf кодируются в base64
+	Payload string `json:"payload"`
+	data    []byte
+}
+
+// Публикация отклоненного сообщения, полученного из канала subject, в канал недоставленных сообщений.
+// Исходное содержимое и его тип contentType передаются без изменений, причина отклонения - в заголовках.
+func publishDeadLetter(ctx context.Context, subject string, payload []byte, contentType string, oErr *orderError, receivedAt time.Time) {
+	msg := nats.NewMsg(DeadLetterSubject)
+	msg.Data = payload
+	msg.Header.Set(HeaderSubject, subject)
+	if contentType != "" {
+		msg.Header.Set(HeaderContentType, contentType)
+	}
+	msg.Header.Set(HeaderFailureStage, oErr.stage)
+	msg.Header.Set(HeaderFailureError, oErr.err.Error())
+	var fieldErrs model.ValidationErrors
+	if errors.As(oErr.err, &fieldErrs) {
+		if fields, err := json.Marshal(fieldErrs); err == nil {
+			msg.Header.Set(HeaderFailureFields, string(fields))
+		}
+	}
+	msg.Header.Set(HeaderReceivedAt, receivedAt.UTC().Format(time.RFC3339Nano))
+	if id := logging.CorrelationID(ctx); id != "" {
+		msg.Header.Set(HeaderCorrelationID, id)
+	}
+
+	if err := Nconn.PublishMsg(msg); err != nil {
+		logger.ErrorContext(ctx, "Ошибка при отправке сообщения в канал недоставленных сообщений", "error", err)
+	}
+}
+
+// In-memory хранилище последних отклоненных сообщений.
+// При превышении емкости самые старые сообщения удаляются.
+type DeadLetterStore struct {
+	mu       sync.Mutex
+	capacity int
+	nextID   uint64
+	items    []DeadLetter
+}
+
+// Создание нового хранилища отклоненных сообщений заданной емкости
+func NewDeadLetterStore(capacity int) *DeadLetterStore {
+	return &DeadLetterStore{capacity: capacity}
+}
+
+// Подписка хранилища на канал недоставленных сообщений
+func SubscribeToDeadLetters(store *DeadLetterStore) (*nats.Subscription, error) {
+	return Nconn.Subscribe(DeadLetterSubject, func(m *nats.Msg) {
+		store.add(m)
+	})
+}
+
+// Добавление сообщения из канала недоставленных сообщений в хранилище
+func (d *DeadLetterStore) add(m *nats.Msg) {
+	receivedAt, _ := time.Parse(time.RFC3339Nano, m.Header.Get(HeaderReceivedAt))
+	subject := m.Header.Get(HeaderSubject)
+	if subject == "" {
+		subject = OrderSubject
+	}
+	var fields model.ValidationErrors
+	if raw := m.Header.Get(HeaderFailureFields); raw != "" {
+		json.Unmarshal([]byte(raw), &fields)
+	}
+	contentType, payload := m.Header.Get(HeaderContentType), string(m.Data)
+	if isProtobuf(contentType) {
+		payload = base64.StdEncoding.EncodeToString(m.Data)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextID++
+	d.items = append(d.items, DeadLetter{
+		ID:            d.nextID,
+		Subject:       subject,
+		Stage:         m.Header.Get(HeaderFailureStage),
+		Error:         m.Header.Get(HeaderFailureError),
+		Fields:        fields,
+		ReceivedAt:    receivedAt,
+		CorrelationID: m.Header.Get(HeaderCorrelationID),
+		ContentType:   contentType,
+		Payload:       payload,
+		data:          m.Data,
+	})
+	if len(d.items) > d.capacity {
+		d.items = d.items[len(d.items)-d.capacity:]
+	}
+}
+
+// Получение списка отклоненных сообщений, начиная с самого старого
+func (d *DeadLetterStore) List() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]DeadLetter(nil), d.items...)
+}
+
+// Повторная публикация исходного содержимого сообщения в канал, из которого оно было получено.
+// После успешной публикации сообщение удаляется из хранилища.
+func (d *DeadLetterStore) Replay(id uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, item := range d.items {
+		if item.ID != id {
+			continue
+		}
+		msg := nats.NewMsg(item.Subject)
+		msg.Data = item.data
+		if item.CorrelationID != "" {
+			msg.Header.Set(HeaderCorrelationID, item.CorrelationID)
+		}
+		if item.ContentType != "" {
+			msg.Header.Set(HeaderContentType, item.ContentType)
+		}
+		if err := Nconn.PublishMsg(msg); err != nil {
+			return fmt.Errorf("ошибка при повторной публикации сообщения %d: %w", id, err)
+		}
+		d.items = append(d.items[:i], d.items[i+1:]...)
+		return nil
+	}
+
+	return ErrDeadLetterNotFound
+}
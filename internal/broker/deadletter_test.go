@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"wbl0/testutils"
+)
+
+func TestDeadLetters_RejectedMessagesCanBeReplayed(t *testing.T) {
+	//Подключение к встроенному серверу NATS
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+	require.NoError(t, Nconn.Flush())
+
+	//Сообщение с некорректным JSON отправляется в канал недоставленных сообщений
+	saver := &fakeSaver{}
+	payload := []byte("{not json")
+	assert.Error(t, processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: payload}))
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	letter := store.List()[0]
+	assert.Equal(t, stageDecode, letter.Stage)
+	assert.NotEmpty(t, letter.Error)
+	assert.Equal(t, string(payload), letter.Payload)
+	assert.WithinDuration(t, time.Now(), letter.ReceivedAt, time.Minute)
+
+	//Повторная отправка публикует исходное содержимое в канал заказов и удаляет сообщение из хранилища
+	orders, err := Nconn.SubscribeSync(OrderSubject)
+	require.NoError(t, err)
+	require.NoError(t, store.Replay(letter.ID))
+
+	msg, err := orders.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, payload, msg.Data)
+	assert.Empty(t, store.List())
+
+	assert.ErrorIs(t, store.Replay(letter.ID), ErrDeadLetterNotFound)
+}
+
+func TestDeadLetters_KeepValidationFields(t *testing.T) {
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+	require.NoError(t, Nconn.Flush())
+
+	//Сумма платежа не сходится с суммой товаров, доставки и пошлины
+	order := testutils.TestOrder
+	order.Payment.Amount++
+	payload, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.Error(t, processOrder(&fakeSaver{}, &nats.Msg{Subject: OrderSubject, Data: payload}))
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	letter := store.List()[0]
+	assert.Equal(t, stageValidate, letter.Stage)
+	require.Len(t, letter.Fields, 1)
+	assert.Equal(t, "payment.amount", letter.Fields[0].Field)
+	assert.Equal(t, "amountSum", letter.Fields[0].Rule)
+}
@@ -0,0 +1,174 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"slices"
+	"time"
+)
+
+// Параметры потока и durable-консьюмеров JetStream для заказов и событий изменения статуса
+const (
+	StreamName        = "ORDERS"
+	DurableName       = "wbl0-orders"
+	StatusDurableName = "wbl0-order-status"
+	MaxDeliver        = 10
+	AckWait           = 30 * time.Second
+)
+
+// Задержка повторной доставки заказа, который не удалось сохранить.
+// С каждой попыткой задержка удваивается, но не превышает NakMaxDelay.
+var (
+	NakBaseDelay = time.Second
+	NakMaxDelay  = time.Minute
+)
+
+var JS nats.JetStreamContext
+
+// Инициализация JetStream и создание потока, покрывающего каналы заказов и событий изменения статуса,
+// и durable-консьюмеров для каждого канала, если они еще не созданы.
+// Консьюмеры создаются явно, а не при подписке, иначе библиотека удалит их при отписке или остановке сервиса.
+func InitJetStream() error {
+	var err error
+	JS, err = Nconn.JetStream()
+	if err != nil {
+		return fmt.Errorf("ошибка при инициализации JetStream: %w", err)
+	}
+
+	subjects := []string{OrderSubject, StatusSubject}
+	info, err := JS.StreamInfo(StreamName)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = JS.AddStream(&nats.StreamConfig{
+			Name:     StreamName,
+			Subjects: subjects,
+			Storage:  nats.FileStorage,
+		})
+	case err == nil && !slices.Contains(info.Config.Subjects, StatusSubject):
+		//Поток, созданный до появления событий изменения статуса
+		cfg := info.Config
+		cfg.Subjects = append(cfg.Subjects, StatusSubject)
+		_, err = JS.UpdateStream(&cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при создании потока %s: %w", StreamName, err)
+	}
+
+	if err := ensureConsumer(DurableName, OrderSubject); err != nil {
+		return err
+	}
+	return ensureConsumer(StatusDurableName, StatusSubject)
+}
+
+// Создание durable-консьюмера для канала subject с группой доставки durable, если он еще не создан
+func ensureConsumer(durable, subject string) error {
+	info, err := JS.ConsumerInfo(StreamName, durable)
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+		_, err = JS.AddConsumer(StreamName, &nats.ConsumerConfig{
+			Durable:        durable,
+			DeliverSubject: nats.NewInbox(),
+			DeliverGroup:   durable,
+			DeliverPolicy:  nats.DeliverAllPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			AckWait:        AckWait,
+			MaxDeliver:     MaxDeliver,
+			FilterSubject:  subject,
+		})
+	case err == nil && info.Config.DeliverGroup != durable:
+		//Консьюмер, созданный без группы доставки, не может быть общим для нескольких экземпляров сервиса
+		cfg := info.Config
+		cfg.DeliverGroup = durable
+		_, err = JS.UpdateConsumer(StreamName, &cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при создании консьюмера %s: %w", durable, err)
+	}
+	return nil
+}
+
+// Подписка на канал заказов через durable-консьюмер JetStream.
+// Сообщение подтверждается только после успешного сохранения заказа через сервис s,
+// поэтому заказы, опубликованные во время простоя сервиса, будут доставлены после его запуска.
+// Заказы, которые не удалось сохранить, возвращаются в поток с нарастающей задержкой.
+// Сообщения с некорректным JSON, не прошедшие валидацию или отклоненные как повторные, повторно не доставляются,
+// а отправляются в канал недоставленных сообщений. Туда же отправляются заказы, которые не удалось сохранить за MaxDeliver попыток.
+// Экземпляры сервиса подписываются на консьюмер в одной группе доставки, и каждое сообщение получает только один из них.
+func SubscribeToJetStream(s OrderSaver) (*nats.Subscription, error) {
+	return subscribeDurable(OrderSubject, DurableName, func(m *nats.Msg) error {
+		return processOrder(s, m)
+	})
+}
+
+// Подписка на канал событий изменения статуса через durable-консьюмер JetStream.
+// События, которые не удалось применить из-за ошибки БД или потому, что заказ еще не сохранен,
+// доставляются повторно так же, как заказы
+func SubscribeToStatusUpdatesJetStream(u StatusUpdater) (*nats.Subscription, error) {
+	return subscribeDurable(StatusSubject, StatusDurableName, func(m *nats.Msg) error {
+		return processStatusUpdate(msgContext(m), u, m.Data)
+	})
+}
+
+// Подписка на канал subject через durable-консьюмер durable с обработкой сообщений функцией process.
+// Успешно обработанные сообщения подтверждаются, отклоненные с ошибкой, которая повторится, больше не доставляются.
+// При ошибке stageSave или stageNotFound сообщение возвращается в поток с задержкой nakDelay, а после последней попытки
+// отправляется в канал недоставленных сообщений.
+func subscribeDurable(subject, durable string, process func(m *nats.Msg) error) (*nats.Subscription, error) {
+	sub, err := JS.QueueSubscribe(subject, durable, func(m *nats.Msg) {
+		err := process(m)
+		if err == nil {
+			m.Ack()
+			return
+		}
+
+		var oErr *orderError
+		if errors.As(err, &oErr) && !oErr.retryable() {
+			m.Term()
+			return
+		}
+		if lastDelivery(m) {
+			//После последней попытки JetStream больше не доставит сообщение, поэтому оно не должно потеряться
+			ctx := msgContext(m)
+			logger.ErrorContext(ctx, "Сообщение не удалось обработать за допустимое число попыток", "subject", subject, "max_deliver", MaxDeliver, "error", err)
+			if oErr == nil {
+				oErr = &orderError{stage: stageSave, err: err}
+			}
+			publishDeadLetter(ctx, subject, m.Data, m.Header.Get(HeaderContentType), oErr, time.Now())
+			m.Term()
+			return
+		}
+		m.NakWithDelay(nakDelay(m))
+	},
+		nats.Bind(StreamName, durable),
+		nats.ManualAck(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подписке на JetStream: %w", err)
+	}
+
+	return sub, nil
+}
+
+// Проверка того, что доставка сообщения m из JetStream последняя из MaxDeliver
+func lastDelivery(m *nats.Msg) bool {
+	meta, err := m.Metadata()
+	return err == nil && meta.NumDelivered >= MaxDeliver
+}
+
+// Вычисление задержки повторной доставки по номеру попытки доставки сообщения
+func nakDelay(m *nats.Msg) time.Duration {
+	delay := NakBaseDelay
+	meta, err := m.Metadata()
+	if err != nil {
+		return delay
+	}
+
+	for i := uint64(1); i < meta.NumDelivered && delay < NakMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > NakMaxDelay {
+		delay = NakMaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,309 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"wbl0/internal/config"
+	"wbl0/internal/database"
+	"wbl0/internal/model"
+	"wbl0/testutils"
+)
+
+// Хранилище заказов для тестов, которое отклоняет первые failures попыток сохранения
+// и выполняет каждое успешное сохранение за время delay. С duplicate все заказы отклоняются как повторные,
+// с batchErr пакеты заказов отклоняются целиком
+type fakeSaver struct {
+	mu        sync.Mutex
+	failures  int
+	duplicate bool
+	batchErr  error
+	delay     time.Duration
+	attempts  int
+	saved     []model.OrderInfo
+	srcs      []model.Source
+}
+
+func (f *fakeSaver) SaveData(_ context.Context, data model.OrderInfo, src model.Source) error {
+	f.mu.Lock()
+	f.attempts++
+	if f.duplicate {
+		f.mu.Unlock()
+		return database.ErrDuplicate
+	}
+	if f.attempts <= f.failures {
+		f.mu.Unlock()
+		return errors.New("база данных недоступна")
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saved = append(f.saved, data)
+	f.srcs = append(f.srcs, src)
+	return nil
+}
+
+// Сохранение пакета по одному заказу, ошибка пакета возвращается при batchErr.
+// Как и в БД, ошибка сохранения заказа, кроме отклонения повторного заказа, возвращается как ошибка пакета
+func (f *fakeSaver) SaveBatch(ctx context.Context, orders []model.OrderInfo, srcs []model.Source) ([]error, error) {
+	if f.batchErr != nil {
+		return nil, f.batchErr
+	}
+	errs := make([]error, len(orders))
+	for i, order := range orders {
+		errs[i] = f.SaveData(ctx, order, srcs[i])
+		if errs[i] != nil && !errors.Is(errs[i], database.ErrDuplicate) {
+			return nil, errs[i]
+		}
+	}
+	return errs, nil
+}
+
+func (f *fakeSaver) state() (int, []model.OrderInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts, f.saved
+}
+
+// Подключение к встроенному серверу NATS
+func initTestNATS(t *testing.T) *server.Server {
+	ns := testutils.RunNATSServer(t)
+
+	cfg := config.Default().NATS
+	cfg.URL = ns.ClientURL()
+	require.NoError(t, InitNATS(cfg))
+	t.Cleanup(Nconn.Close)
+
+	return ns
+}
+
+// Подключение к встроенному серверу NATS и создание потока заказов
+func initTestJetStream(t *testing.T) *server.Server {
+	ns := initTestNATS(t)
+	require.NoError(t, InitJetStream())
+
+	NakBaseDelay = 10 * time.Millisecond
+	t.Cleanup(func() { NakBaseDelay = time.Second })
+
+	return ns
+}
+
+func TestSubscribeToJetStream_DeliversOrdersPublishedBeforeSubscribe(t *testing.T) {
+	initTestJetStream(t)
+
+	//Публикация заказа до запуска подписчика, как если бы сервис был остановлен
+	payload, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	_, err = JS.Publish(OrderSubject, payload)
+	require.NoError(t, err)
+
+	//Первые две попытки сохранения завершаются ошибкой, сообщение должно быть доставлено повторно
+	saver := &fakeSaver{failures: 2}
+	sub, err := SubscribeToJetStream(saver)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	assert.Eventually(t, func() bool {
+		_, saved := saver.state()
+		return len(saved) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	attempts, saved := saver.state()
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, testutils.TestOrder, saved[0])
+
+	//После сохранения сообщение подтверждено и не ожидает повторной доставки
+	assert.Eventually(t, func() bool {
+		info, err := JS.ConsumerInfo(StreamName, DurableName)
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeToJetStream_TerminatesInvalidOrders(t *testing.T) {
+	initTestJetStream(t)
+
+	saver := &fakeSaver{}
+	sub, err := SubscribeToJetStream(saver)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	//Некорректный JSON не должен доставляться повторно и не должен сохраняться
+	_, err = JS.Publish(OrderSubject, []byte("{not json"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		info, err := JS.ConsumerInfo(StreamName, DurableName)
+		return err == nil && info.AckFloor.Stream == 1 && info.NumAckPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	attempts, _ := saver.state()
+	assert.Equal(t, 0, attempts)
+}
+
+func TestSubscribeToJetStream_TerminatesDuplicates(t *testing.T) {
+	initTestJetStream(t)
+
+	saver := &fakeSaver{duplicate: true}
+	sub, err := SubscribeToJetStream(saver)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	//Отклоненный повторный заказ не должен доставляться повторно
+	payload, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	_, err = JS.Publish(OrderSubject, payload)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		info, err := JS.ConsumerInfo(StreamName, DurableName)
+		return err == nil && info.AckFloor.Stream == 1 && info.NumAckPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	attempts, _ := saver.state()
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDrain_WaitsForInFlightSaveAndKeepsDurableConsumer(t *testing.T) {
+	ns := initTestJetStream(t)
+
+	saver := &fakeSaver{delay: 200 * time.Millisecond}
+	_, err := SubscribeToJetStream(saver)
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	_, err = JS.Publish(OrderSubject, payload)
+	require.NoError(t, err)
+
+	//Остановка во время сохранения заказа дожидается его завершения и подтверждения
+	assert.Eventually(t, func() bool {
+		attempts, _ := saver.state()
+		return attempts == 1
+	}, 5*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Drain(ctx))
+
+	_, saved := saver.state()
+	assert.Len(t, saved, 1)
+	assert.True(t, Nconn.IsClosed())
+
+	//Durable-консьюмер сохраняется после остановки, сообщение подтверждено
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	info, err := js.ConsumerInfo(StreamName, DurableName)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.AckFloor.Stream)
+	assert.Equal(t, 0, info.NumAckPending)
+}
+
+func TestSubscribeToJetStream_SharesConsumerBetweenInstances(t *testing.T) {
+	initTestJetStream(t)
+
+	//Два экземпляра сервиса подписаны на один durable-консьюмер
+	first, second := &fakeSaver{}, &fakeSaver{}
+	for _, saver := range []*fakeSaver{first, second} {
+		sub, err := SubscribeToJetStream(saver)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+	}
+
+	const orders = 20
+	for i := 0; i < orders; i++ {
+		order := testutils.TestOrder
+		order.OrderUid = "order" + strconv.Itoa(i)
+		payload, err := json.Marshal(order)
+		require.NoError(t, err)
+		_, err = JS.Publish(OrderSubject, payload)
+		require.NoError(t, err)
+	}
+
+	//Каждый заказ обрабатывается ровно одним экземпляром
+	assert.Eventually(t, func() bool {
+		_, a := first.state()
+		_, b := second.state()
+		return len(a)+len(b) == orders
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	_, a := first.state()
+	_, b := second.state()
+	assert.Len(t, append(a, b...), orders)
+}
+
+func TestInitJetStream_AddsDeliverGroupToExistingConsumer(t *testing.T) {
+	initTestNATS(t)
+
+	//Консьюмер, созданный предыдущей версией сервиса без группы доставки
+	js, err := Nconn.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: StreamName, Subjects: []string{OrderSubject}})
+	require.NoError(t, err)
+	_, err = js.AddConsumer(StreamName, &nats.ConsumerConfig{
+		Durable:        DurableName,
+		DeliverSubject: nats.NewInbox(),
+		AckPolicy:      nats.AckExplicitPolicy,
+		MaxDeliver:     MaxDeliver,
+		FilterSubject:  OrderSubject,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, InitJetStream())
+	info, err := JS.ConsumerInfo(StreamName, DurableName)
+	require.NoError(t, err)
+	assert.Equal(t, DurableName, info.Config.DeliverGroup)
+
+	sub, err := SubscribeToJetStream(&fakeSaver{})
+	require.NoError(t, err)
+	sub.Unsubscribe()
+}
+
+func TestSubscribeToJetStream_DeadLettersAfterMaxDeliver(t *testing.T) {
+	initTestJetStream(t)
+	NakMaxDelay = 20 * time.Millisecond
+	t.Cleanup(func() { NakMaxDelay = time.Minute })
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+
+	//Заказ не удается сохранить ни с одной попытки
+	saver := &fakeSaver{failures: MaxDeliver + 1}
+	sub, err := SubscribeToJetStream(saver)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	payload, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	_, err = JS.Publish(OrderSubject, payload)
+	require.NoError(t, err)
+
+	//После последней попытки заказ отправляется в канал недоставленных сообщений
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, stageSave, store.List()[0].Stage)
+	assert.Equal(t, string(payload), store.List()[0].Payload)
+
+	attempts, _ := saver.state()
+	assert.Equal(t, MaxDeliver, attempts)
+	assert.Eventually(t, func() bool {
+		info, err := JS.ConsumerInfo(StreamName, DurableName)
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,56 @@
+package broker
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"regexp"
+	"wbl0/internal/model"
+)
+
+var (
+	messagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wbl0_broker_messages_received_total",
+		Help: "Количество полученных заказов, каждый заказ пакета учитывается отдельно",
+	})
+	messagesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wbl0_broker_messages_rejected_total",
+		Help: "Количество сообщений, которые не удалось обработать, по этапу обработки",
+	}, []string{"stage"})
+	messagesSaved = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wbl0_broker_messages_saved_total",
+		Help: "Количество сохраненных заказов",
+	})
+	statusUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wbl0_broker_status_updates_total",
+		Help: "Количество событий изменения статуса по результату: ok или этап, на котором событие отклонено",
+	}, []string{"result"})
+	validationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wbl0_broker_validation_failures_total",
+		Help: "Количество ошибок валидации заказов по полям",
+	}, []string{"field"})
+)
+
+// Индексы элементов массивов в пути к полю. В метках они заменяются на [], чтобы число меток не зависело от размера заказов
+var fieldIndex = regexp.MustCompile(`\[\d+\]`)
+
+// Метка для полей, которых нет в JSON Schema заказа
+const unknownFieldLabel = "<unknown>"
+
+// Учет ошибки обработки сообщения. Для ошибок валидации и проверки по схеме учитывается каждое поле, не прошедшее проверку.
+// Имена неизвестных полей задает отправитель, поэтому они учитываются под одной меткой, а сами имена остаются
+// в журнале и в причине отклонения сообщения
+func observeRejected(oErr *orderError) {
+	messagesRejected.WithLabelValues(oErr.stage).Inc()
+
+	var fieldErrs model.ValidationErrors
+	if (oErr.stage == stageValidate || oErr.stage == stageSchema) && errors.As(oErr.err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			field := fieldIndex.ReplaceAllString(fe.Field, "[]")
+			if fe.Rule == ruleUnknownField {
+				field = unknownFieldLabel
+			}
+			validationFailures.WithLabelValues(field).Inc()
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package broker
+
+import (
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/testutils"
+)
+
+func TestProcessOrder_Metrics(t *testing.T) {
+	initTestNATS(t)
+
+	received := testutil.ToFloat64(messagesReceived)
+	saved := testutil.ToFloat64(messagesSaved)
+	rejected := testutil.ToFloat64(messagesRejected.WithLabelValues(stageValidate))
+	field := testutil.ToFloat64(validationFailures.WithLabelValues("track_number"))
+
+	//Корректный заказ сохраняется
+	payload, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	assert.NoError(t, processOrder(&fakeSaver{}, &nats.Msg{Subject: OrderSubject, Data: payload}))
+
+	//Заказ с недопустимыми символами в трек-номере отклоняется на этапе валидации
+	order := testutils.TestOrder
+	order.TrackNumber = "WB;--"
+	payload, err = json.Marshal(order)
+	require.NoError(t, err)
+	assert.Error(t, processOrder(&fakeSaver{}, &nats.Msg{Subject: OrderSubject, Data: payload}))
+
+	assert.Equal(t, received+2, testutil.ToFloat64(messagesReceived))
+	assert.Equal(t, saved+1, testutil.ToFloat64(messagesSaved))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(messagesRejected.WithLabelValues(stageValidate)))
+	assert.Equal(t, field+1, testutil.ToFloat64(validationFailures.WithLabelValues("track_number")))
+}
@@ -1,53 +1,231 @@
 package broker
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/nats-io/nats.go"
-	"log"
-	"sync"
+	"log/slog"
+	"time"
+	"wbl0/internal/config"
+	"wbl0/internal/database"
+	"wbl0/internal/logging"
 	"wbl0/internal/model"
-	"wbl0/internal/service"
 )
 
+// Канал NATS, в который публикуются заказы
+const OrderSubject = "order_info"
+
 var Nconn *nats.Conn
 
+// Хранилище, в которое брокер передает полученные заказы (реализуется service.Service)
+type OrderSaver interface {
+	SaveData(ctx context.Context, data model.OrderInfo, src model.Source) error
+	//Сохранение пакета заказов с источниками srcs в порядке orders. Возвращает ошибки отдельных заказов,
+	//которые повторятся при повторной доставке, и ошибку, если пакет не удалось сохранить целиком
+	SaveBatch(ctx context.Context, orders []model.OrderInfo, srcs []model.Source) ([]error, error)
+}
+
+// Заголовок сообщения с идентификатором корреляции.
+// Если заголовка нет, используется Nats-Msg-Id, а при его отсутствии идентификатор создается заново.
+const HeaderCorrelationID = "Wbl0-Correlation-Id"
+
+// Журнал брокера
+var logger = slog.Default()
+
+// Установка журнала брокера. По умолчанию используется slog.Default()
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// Строгая проверка сообщений по JSON Schema: неизвестные поля заказа отклоняются
+var strictSchema bool
+
+// Включение или отключение строгой проверки сообщений по JSON Schema
+func SetStrictSchema(strict bool) {
+	strictSchema = strict
+}
+
+// Режим проверки сообщений по JSON Schema. HTTP-сервер публикует схему в том же режиме
+func StrictSchema() bool {
+	return strictSchema
+}
+
+// Этапы обработки сообщения с заказом
+const (
+	stageDecode = "decode"
+	//Сообщение не соответствует JSON Schema заказа
+	stageSchema   = "schema"
+	stageValidate = "validate"
+	stageSave     = "save"
+	//Заказ уже сохранен, а политика сохранения повторных заказов запрещает его повторное сохранение
+	stageDuplicate = "duplicate"
+)
+
+// Ошибка обработки сообщения с указанием этапа, на котором она произошла
+type orderError struct {
+	stage string
+	err   error
+}
+
+func (e *orderError) Error() string {
+	return fmt.Sprintf("%s: %v", e.stage, e.err)
+}
+
+func (e *orderError) Unwrap() error {
+	return e.err
+}
+
+// Проверка того, что ошибка может не повториться при повторной доставке сообщения:
+// ошибка БД или событие изменения статуса, полученное раньше заказа
+func (e *orderError) retryable() bool {
+	return e.stage == stageSave || e.stage == stageNotFound
+}
+
+// Канал закрывается, когда соединение с NATS закрыто
+var closed chan struct{}
+
 // Соединение с сервером NATS
-func InitNATS() {
-	var err error
-	Nconn, err = nats.Connect("http://localhost:4222")
+func InitNATS(cfg config.NATS) error {
+	DeadLetterSubject = cfg.DeadLetterSubject
+
+	//Обработчик закрывает канал своего соединения, а не канал, созданный следующим вызовом InitNATS
+	done := make(chan struct{})
+	conn, err := nats.Connect(cfg.URL, nats.ClosedHandler(func(*nats.Conn) {
+		close(done)
+	}))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("ошибка при подключении к NATS: %w", err)
+	}
+	Nconn, closed = conn, done
+	return nil
+}
+
+// Проверка соединения с NATS для /readyz. Возвращает ошибку, если соединение не установлено или разорвано
+func CheckConnection(context.Context) error {
+	if Nconn == nil {
+		return errors.New("соединение с NATS не установлено")
+	}
+	if status := Nconn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("соединение с NATS в состоянии %s", status)
+	}
+	return nil
+}
+
+// Плавная остановка работы с NATS.
+// Все подписки перестают получать новые сообщения, уже полученные сообщения обрабатываются до конца
+// (включая сохранение заказов в БД и подтверждение в JetStream), после чего соединение закрывается.
+// Если обработка не завершилась до отмены ctx, возвращается ошибка контекста.
+func Drain(ctx context.Context) error {
+	if err := Nconn.Drain(); err != nil {
+		return fmt.Errorf("ошибка при остановке подписок NATS: %w", err)
+	}
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		Nconn.Close()
+		return ctx.Err()
 	}
 }
 
 // Подписка на указанный канал в сервере NATS и обработка полученных сообщений.
-// Когда сообщение получено, оно декодируется из JSON в объект OrderInfo.
+// Когда сообщение получено, оно декодируется в объект OrderInfo из JSON или protobuf в зависимости от заголовка Content-Type.
+// Сообщение может содержать пакет заказов в виде JSON-массива или NDJSON, тогда заказы пакета сохраняются одной транзакцией.
 // Затем данные проходят валидацию, и если они проходят проверку, они сохраняются в кэш и БД через сервис s.
 // Если происходит ошибка на любом из этапов, она регистрируется в журнале.
-func SubscribeToNATS(s *service.Service, wg *sync.WaitGroup) {
-	Nconn.Subscribe("order_info", func(m *nats.Msg) {
-		defer wg.Done()
-		var order model.OrderInfo
-		err := json.Unmarshal(m.Data, &order)
-		if err != nil {
-			fmt.Println("Ошибка при разборе JSON:", err)
-			return
-		}
-
-		fmt.Println("Получен заказ из NATS:", order)
-		err = order.Validate()
-		if err != nil {
-			fmt.Println("Ошибка валидации данных:", err)
-			return
-		}
-		fmt.Println("Валидация данных успешно завершена")
-
-		err = s.SaveData(order)
-		if err != nil {
-			fmt.Println("Ошибка при сохранении данных:", err)
-		} else {
-			fmt.Println("Данные успешно сохранены")
-		}
+func SubscribeToNATS(s OrderSaver) (*nats.Subscription, error) {
+	return Nconn.Subscribe(OrderSubject, func(m *nats.Msg) {
+		processOrder(s, m)
 	})
 }
+
+// Контекст обработки сообщения с идентификатором корреляции
+func msgContext(m *nats.Msg) context.Context {
+	id := m.Header.Get(HeaderCorrelationID)
+	if id == "" {
+		id = m.Header.Get(nats.MsgIdHdr)
+	}
+	if id == "" {
+		id = logging.NewCorrelationID()
+	}
+	return logging.WithCorrelationID(context.Background(), id)
+}
+
+// Источник заказа для журнала аудита: канал, номер сообщения в JetStream, время получения и хэш содержимого
+func messageSource(m *nats.Msg) model.Source {
+	src := model.Source{
+		Subject:    m.Subject,
+		ReceivedAt: time.Now(),
+	}
+	//Метаданные есть только у сообщений, полученных из JetStream
+	if meta, err := m.Metadata(); err == nil {
+		src.Sequence = meta.Sequence.Stream
+	}
+	src.PayloadHash = payloadHash(m.Data)
+	return src
+}
+
+// SHA-256 содержимого сообщения или заказа из пакета в шестнадцатеричном виде
+func payloadHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Разбор, валидация и сохранение заказа из тела сообщения m.
+// Сообщения, которые не удалось разобрать или провалидировать, и отклоненные повторные заказы
+// отправляются в канал недоставленных сообщений.
+// Сообщения с пакетом заказов (JSON-массив или NDJSON) обрабатываются в processBatch.
+// Возвращает *orderError с этапом, на котором произошла ошибка.
+func processOrder(s OrderSaver, m *nats.Msg) error {
+	ctx := msgContext(m)
+	src := messageSource(m)
+	payload, receivedAt := m.Data, src.ReceivedAt
+	contentType := m.Header.Get(HeaderContentType)
+
+	if isBatch(payload, contentType) {
+		return processBatch(ctx, s, payload, contentType, src)
+	}
+	messagesReceived.Inc()
+
+	order, oErr := decodeOrder(payload, contentType)
+	if oErr != nil {
+		logger.WarnContext(ctx, "Ошибка при разборе заказа", "stage", oErr.stage, "error", oErr.err)
+		observeRejected(oErr)
+		publishDeadLetter(ctx, OrderSubject, payload, contentType, oErr, receivedAt)
+		return oErr
+	}
+
+	logger.InfoContext(ctx, "Получен заказ из NATS", "order", order)
+	err := order.Validate()
+	if err != nil {
+		logger.WarnContext(ctx, "Ошибка валидации данных", "order_uid", order.OrderUid, "error", err)
+		oErr := &orderError{stage: stageValidate, err: err}
+		observeRejected(oErr)
+		publishDeadLetter(ctx, OrderSubject, payload, contentType, oErr, receivedAt)
+		return oErr
+	}
+	logger.DebugContext(ctx, "Валидация данных успешно завершена", "order_uid", order.OrderUid)
+
+	err = s.SaveData(ctx, order, src)
+	if errors.Is(err, database.ErrDuplicate) {
+		//Повторная доставка не изменит результат, поэтому заказ отклоняется так же, как некорректный
+		logger.WarnContext(ctx, "Повторный заказ отклонен", "order_uid", order.OrderUid)
+		oErr := &orderError{stage: stageDuplicate, err: err}
+		observeRejected(oErr)
+		publishDeadLetter(ctx, OrderSubject, payload, contentType, oErr, receivedAt)
+		return oErr
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при сохранении данных", "order_uid", order.OrderUid, "error", err)
+		oErr := &orderError{stage: stageSave, err: err}
+		observeRejected(oErr)
+		return oErr
+	}
+	messagesSaved.Inc()
+	logger.InfoContext(ctx, "Данные успешно сохранены", "order_uid", order.OrderUid)
+	return nil
+}
@@ -1,12 +1,16 @@
 package broker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
-	"sync"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 	"wbl0/internal/cache"
+	"wbl0/internal/config"
 	"wbl0/internal/database"
 	"wbl0/internal/model"
 	"wbl0/internal/service"
@@ -14,10 +18,6 @@ import (
 )
 
 func TestSubscribeToNATS(t *testing.T) {
-	//Добавление горутины в WaitGroup
-	var wg sync.WaitGroup
-	wg.Add(1)
-
 	//Подготовка к тестированию брокера NATS
 	//Создание экземпляра сервиса и кэша
 	db := testutils.InitTestDatabase(t)
@@ -28,12 +28,14 @@ func TestSubscribeToNATS(t *testing.T) {
 	s := service.NewService(pgDB, cache)
 
 	//Инициализация брокера
-	InitNATS()
-	SubscribeToNATS(s, &wg)
+	require.NoError(t, InitNATS(config.Default().NATS))
+	_, err := SubscribeToNATS(s)
+	assert.NoError(t, err)
 
-	//Публикация тестового заказа в брокер
+	//Публикация тестового заказа в брокер и ожидание завершения его обработки
 	PublishOrderToNATS(testutils.TestOrder)
-	wg.Wait()
+	assert.NoError(t, Nconn.Flush())
+	assert.NoError(t, Drain(context.Background()))
 
 	//Проверка сохранения данных заказа в БД и кэше
 	data, err := s.GetDataById(testutils.TestOrder.OrderUid)
@@ -58,3 +60,38 @@ func PublishOrderToNATS(order model.OrderInfo) {
 		fmt.Println("Данные успешно отправлены в канал")
 	}
 }
+
+func TestCheckConnection(t *testing.T) {
+	initTestNATS(t)
+	assert.NoError(t, CheckConnection(context.Background()))
+
+	//После закрытия соединения сервис не готов принимать заказы
+	Nconn.Close()
+	assert.Error(t, CheckConnection(context.Background()))
+}
+
+func TestDrain_IgnoresPreviousConnection(t *testing.T) {
+	ns := initTestNATS(t)
+	previous := Nconn
+
+	//Закрытие предыдущего соединения не завершает остановку нового
+	cfg := config.Default().NATS
+	cfg.URL = ns.ClientURL()
+	require.NoError(t, InitNATS(cfg))
+	previous.Close()
+	assert.True(t, Nconn.IsConnected())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Drain(ctx))
+	assert.True(t, Nconn.IsClosed())
+}
+
+func TestMessageSource(t *testing.T) {
+	src := messageSource(&nats.Msg{Subject: OrderSubject, Data: []byte("{}")})
+	assert.Equal(t, OrderSubject, src.Subject)
+	//Сообщение получено не из JetStream
+	assert.Zero(t, src.Sequence)
+	assert.Equal(t, "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", src.PayloadHash)
+	assert.WithinDuration(t, time.Now(), src.ReceivedAt, time.Minute)
+}
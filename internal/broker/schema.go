@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"wbl0/internal/model"
+	"wbl0/internal/schema"
+)
+
+// Правило ошибки для поля, которого нет в JSON Schema заказа. Путь к такому полю задает отправитель
+const ruleUnknownField = "unknownField"
+
+// Проверка тела сообщения с заказом по JSON Schema.
+// Несоответствия возвращаются как model.ValidationErrors с правилом schema (unknownField для неизвестных полей)
+// и путями полей в том же формате, что и ошибки валидации заказа.
+func validateSchema(payload []byte) error {
+	s := model.OrderSchema(strictSchema)
+	err := schema.ValidateJSON(s, s.Defs, payload)
+
+	var schemaErrs schema.Errors
+	if !errors.As(err, &schemaErrs) {
+		return err
+	}
+	errs := make(model.ValidationErrors, len(schemaErrs))
+	for i, e := range schemaErrs {
+		rule := "schema"
+		if e.Message == schema.UnknownFieldMessage {
+			rule = ruleUnknownField
+		}
+		errs[i] = model.FieldError{Field: fieldPath(e.Path), Rule: rule, Message: e.Message}
+	}
+	return errs
+}
+
+// Преобразование JSON Pointer в путь к полю: /items/0/rid -> items[0].rid
+func fieldPath(pointer string) string {
+	var b strings.Builder
+	for _, segment := range strings.Split(strings.Trim(pointer, "/"), "/") {
+		switch {
+		case segment == "":
+		case strings.Trim(segment, "0123456789") == "":
+			b.WriteString("[" + segment + "]")
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(segment)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/internal/model"
+	"wbl0/testutils"
+)
+
+func TestFieldPath(t *testing.T) {
+	assert.Equal(t, "", fieldPath("/"))
+	assert.Equal(t, "order_uid", fieldPath("/order_uid"))
+	assert.Equal(t, "delivery.floor", fieldPath("/delivery/floor"))
+	assert.Equal(t, "items[0].rid", fieldPath("/items/0/rid"))
+}
+
+func TestProcessOrder_Schema(t *testing.T) {
+	initTestNATS(t)
+	t.Cleanup(func() { SetStrictSchema(false) })
+
+	//Заказ с неизвестным полем доставки
+	var raw map[string]any
+	data, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &raw))
+	raw["delivery"].(map[string]any)["floor"] = 3
+	payload, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	//Без строгого режима неизвестное поле игнорируется
+	saver := &fakeSaver{}
+	assert.NoError(t, processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: payload}))
+
+	//В строгом режиме сообщение отклоняется до разбора
+	SetStrictSchema(true)
+	unknown := testutil.ToFloat64(validationFailures.WithLabelValues(unknownFieldLabel))
+	err = processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: payload})
+	var oErr *orderError
+	require.ErrorAs(t, err, &oErr)
+	assert.Equal(t, stageSchema, oErr.stage)
+	var errs model.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, model.FieldError{Field: "delivery.floor", Rule: ruleUnknownField, Message: "неизвестное поле"}, errs[0])
+
+	//Имя неизвестного поля задает отправитель, поэтому оно не попадает в метки метрики
+	assert.Equal(t, unknown+1, testutil.ToFloat64(validationFailures.WithLabelValues(unknownFieldLabel)))
+	assert.Zero(t, testutil.ToFloat64(validationFailures.WithLabelValues("delivery.floor")))
+
+	//Пропущенное обязательное поле отклоняется в любом режиме, некорректный JSON - на этапе разбора
+	SetStrictSchema(false)
+	delete(raw, "track_number")
+	payload, err = json.Marshal(raw)
+	require.NoError(t, err)
+	require.ErrorAs(t, processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: payload}), &oErr)
+	assert.Equal(t, stageSchema, oErr.stage)
+
+	require.ErrorAs(t, processOrder(saver, &nats.Msg{Subject: OrderSubject, Data: []byte("{not json")}), &oErr)
+	assert.Equal(t, stageDecode, oErr.stage)
+}
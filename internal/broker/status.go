@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"time"
+	"wbl0/internal/database"
+	"wbl0/internal/model"
+)
+
+// Канал NATS, в который публикуются события изменения статуса товаров заказа
+const StatusSubject = "order_status"
+
+// Этапы обработки события изменения статуса
+const (
+	//Переход в новый статус недопустим для текущего статуса товара, событие отклоняется без повторных попыток
+	stageTransition = "transition"
+	//Заказ или товар еще не сохранен. Каналы заказов и статусов не упорядочены между собой,
+	//поэтому событие доставляется повторно так же, как при ошибке БД
+	stageNotFound = "not_found"
+)
+
+// Сервис, который применяет события изменения статуса (реализуется service.Service)
+type StatusUpdater interface {
+	UpdateStatus(ctx context.Context, update model.StatusUpdate) error
+}
+
+// Подписка на канал событий изменения статуса.
+// Событие декодируется из JSON в model.StatusUpdate, проверяется и применяется через сервис u.
+// События, которые не удалось разобрать, провалидировать или применить к текущему статусу,
+// отправляются в канал недоставленных сообщений. Без JetStream повторной доставки нет,
+// поэтому туда же отправляются события, которые не удалось сохранить из-за ошибки БД или отсутствия заказа.
+func SubscribeToStatusUpdates(u StatusUpdater) (*nats.Subscription, error) {
+	return Nconn.Subscribe(StatusSubject, func(m *nats.Msg) {
+		ctx := msgContext(m)
+		receivedAt := time.Now()
+		var oErr *orderError
+		if errors.As(processStatusUpdate(ctx, u, m.Data), &oErr) && oErr.retryable() {
+			publishDeadLetter(ctx, StatusSubject, m.Data, "", oErr, receivedAt)
+		}
+	})
+}
+
+// Разбор, валидация и применение события изменения статуса.
+// Отклоненные события, кроме не сохраненных из-за ошибки БД или отсутствия заказа, отправляются в канал недоставленных сообщений.
+// Возвращает *orderError с этапом, на котором произошла ошибка.
+func processStatusUpdate(ctx context.Context, u StatusUpdater, payload []byte) error {
+	receivedAt := time.Now()
+
+	var update model.StatusUpdate
+	oErr := decodeStatusUpdate(payload, &update)
+	if oErr == nil {
+		err := u.UpdateStatus(ctx, update)
+		switch {
+		case errors.Is(err, model.ErrInvalidTransition):
+			oErr = &orderError{stage: stageTransition, err: err}
+		case errors.Is(err, database.ErrNotFound):
+			oErr = &orderError{stage: stageNotFound, err: err}
+		case err != nil:
+			oErr = &orderError{stage: stageSave, err: err}
+		}
+	}
+
+	if oErr == nil {
+		//Изменение статуса записывается в журнал сервисом
+		statusUpdates.WithLabelValues("ok").Inc()
+		return nil
+	}
+
+	statusUpdates.WithLabelValues(oErr.stage).Inc()
+	switch oErr.stage {
+	case stageSave:
+		logger.ErrorContext(ctx, "Ошибка при изменении статуса заказа", "order_uid", update.OrderUid, "error", oErr.err)
+		return oErr
+	case stageNotFound:
+		logger.WarnContext(ctx, "Заказ для события изменения статуса еще не сохранен", "order_uid", update.OrderUid, "error", oErr.err)
+		return oErr
+	}
+	logger.WarnContext(ctx, "Событие изменения статуса отклонено", "order_uid", update.OrderUid, "stage", oErr.stage, "error", oErr.err)
+	publishDeadLetter(ctx, StatusSubject, payload, "", oErr, receivedAt)
+	return oErr
+}
+
+// Декодирование и валидация события изменения статуса
+func decodeStatusUpdate(payload []byte, update *model.StatusUpdate) *orderError {
+	if err := json.Unmarshal(payload, update); err != nil {
+		return &orderError{stage: stageDecode, err: err}
+	}
+	if err := update.Validate(); err != nil {
+		return &orderError{stage: stageValidate, err: err}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+	"wbl0/internal/database"
+	"wbl0/internal/model"
+)
+
+// Сервис для тестов, который применяет переходы статуса к заказам в памяти.
+// Первые failures попыток отклоняются ошибкой БД
+type fakeUpdater struct {
+	mu       sync.Mutex
+	statuses map[string]int
+	failures int
+	attempts int
+}
+
+func (f *fakeUpdater) UpdateStatus(_ context.Context, update model.StatusUpdate) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("база данных недоступна")
+	}
+	current, ok := f.statuses[update.OrderUid]
+	if !ok {
+		return fmt.Errorf("заказ %s: %w", update.OrderUid, database.ErrNotFound)
+	}
+	if err := model.ValidateTransition(current, update.Status); err != nil {
+		return err
+	}
+	f.statuses[update.OrderUid] = update.Status
+	return nil
+}
+
+// Сохранение заказа с товарами в статусе status
+func (f *fakeUpdater) add(orderUid string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[orderUid] = status
+}
+
+func (f *fakeUpdater) status(orderUid string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statuses[orderUid]
+}
+
+func TestProcessStatusUpdate(t *testing.T) {
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+	require.NoError(t, Nconn.Flush())
+
+	updater := &fakeUpdater{statuses: map[string]int{"order": model.StatusAccepted}}
+	ctx := context.Background()
+
+	//Допустимый переход применяется
+	assert.NoError(t, processStatusUpdate(ctx, updater, []byte(`{"order_uid":"order","status":203}`)))
+	assert.Equal(t, model.StatusAssembled, updater.statuses["order"])
+
+	//Недопустимый переход и некорректное событие отклоняются и попадают в канал недоставленных сообщений
+	assert.Error(t, processStatusUpdate(ctx, updater, []byte(`{"order_uid":"order","status":205}`)))
+	assert.Error(t, processStatusUpdate(ctx, updater, []byte(`{"order_uid":"order","status":999}`)))
+	assert.Equal(t, model.StatusAssembled, updater.statuses["order"])
+
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	letters := store.List()
+	assert.Equal(t, stageTransition, letters[0].Stage)
+	assert.Equal(t, stageValidate, letters[1].Stage)
+	assert.Equal(t, StatusSubject, letters[0].Subject)
+
+	//Повторная отправка публикует событие в канал статусов
+	statuses, err := Nconn.SubscribeSync(StatusSubject)
+	require.NoError(t, err)
+	require.NoError(t, store.Replay(letters[0].ID))
+
+	msg, err := statuses.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"order_uid":"order","status":205}`, string(msg.Data))
+}
+
+func TestSubscribeToStatusUpdates_DeadLettersSaveErrors(t *testing.T) {
+	initTestNATS(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+
+	updater := &fakeUpdater{statuses: map[string]int{"order": model.StatusAccepted}, failures: 1}
+	sub, err := SubscribeToStatusUpdates(updater)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	//Без JetStream событие, которое не удалось сохранить, не теряется, а попадает в канал недоставленных сообщений
+	require.NoError(t, Nconn.Publish(StatusSubject, []byte(`{"order_uid":"order","status":203}`)))
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, stageSave, store.List()[0].Stage)
+	assert.Equal(t, StatusSubject, store.List()[0].Subject)
+	assert.Equal(t, model.StatusAccepted, updater.status("order"))
+
+	//Событие для еще не сохраненного заказа тоже не теряется
+	require.NoError(t, Nconn.Publish(StatusSubject, []byte(`{"order_uid":"late","status":203}`)))
+	assert.Eventually(t, func() bool {
+		return len(store.List()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, stageNotFound, store.List()[1].Stage)
+}
+
+func TestSubscribeToStatusUpdatesJetStream_RedeliversSaveErrors(t *testing.T) {
+	initTestJetStream(t)
+
+	updater := &fakeUpdater{statuses: map[string]int{"order": model.StatusAccepted}, failures: 2}
+	sub, err := SubscribeToStatusUpdatesJetStream(updater)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	//Событие повторно доставляется, пока не будет сохранено
+	_, err = JS.Publish(StatusSubject, []byte(`{"order_uid":"order","status":203}`))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return updater.status("order") == model.StatusAssembled
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		info, err := JS.ConsumerInfo(StreamName, StatusDurableName)
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeToStatusUpdatesJetStream_RedeliversUntilOrderSaved(t *testing.T) {
+	initTestJetStream(t)
+
+	store := NewDeadLetterStore(10)
+	_, err := SubscribeToDeadLetters(store)
+	require.NoError(t, err)
+
+	updater := &fakeUpdater{statuses: map[string]int{}}
+	sub, err := SubscribeToStatusUpdatesJetStream(updater)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	//Событие получено раньше заказа и доставляется повторно, пока заказ не будет сохранен
+	_, err = JS.Publish(StatusSubject, []byte(`{"order_uid":"late","status":203}`))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		updater.mu.Lock()
+		defer updater.mu.Unlock()
+		return updater.attempts >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	updater.add("late", model.StatusAccepted)
+	assert.Eventually(t, func() bool {
+		return updater.status("late") == model.StatusAssembled
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, store.List())
+}
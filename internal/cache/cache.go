@@ -1,38 +1,217 @@
 package cache
 
 import (
+	"container/list"
+	"reflect"
 	"sync"
+	"time"
 	"wbl0/internal/model"
 )
 
-// Структура кэша для хранения заказов in-memory
+// Кэш заказов, с которым работает сервис
+type OrderCache interface {
+	GetById(id string) (model.OrderInfo, bool)
+	SetById(id string, data model.OrderInfo)
+	Range(fn func(id string, data model.OrderInfo) bool)
+	Stats() Stats
+}
+
+// Счетчики работы кэша
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Bytes       int64
+}
+
+// Структура кэша для хранения заказов in-memory.
+// Размер кэша может быть ограничен количеством записей и/или их суммарным размером в байтах,
+// при превышении ограничений вытесняются давно не использовавшиеся записи (LRU).
+// Для записей может быть задано время жизни (TTL).
 type Cache struct {
-	mu   sync.Mutex
-	data map[string]model.OrderInfo
+	mu    sync.Mutex
+	data  map[string]*list.Element
+	lru   *list.List
+	opts  options
+	now   func() time.Time
+	stats Stats
 }
 
-// Создать новый экземпляр кэша и вернуть указатель на него
-func NewCache() *Cache {
-	return &Cache{
-		data: make(map[string]model.OrderInfo),
+// Запись кэша
+type entry struct {
+	id      string
+	order   model.OrderInfo
+	size    int64
+	expires time.Time
+}
+
+// Ограничения кэша
+type options struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+}
+
+// Параметр кэша
+type Option func(o *options)
+
+// Ограничение количества записей в кэше. 0 - без ограничения
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// Ограничение суммарного размера записей в кэше в байтах. 0 - без ограничения
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
 	}
 }
 
+// Время жизни записи в кэше с момента ее сохранения. 0 - записи не устаревают
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// Создать новый экземпляр кэша и вернуть указатель на него.
+// Без параметров кэш не ограничен по размеру и времени жизни записей.
+func NewCache(opts ...Option) *Cache {
+	c := &Cache{
+		data: make(map[string]*list.Element),
+		lru:  list.New(),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
 // Получение данных заказа по его ID.
-// Если данные для указанного ID отсутствуют в кэше, возвращается второй аргумент со значением false.
+// Если данные для указанного ID отсутствуют в кэше или устарели, возвращается второй аргумент со значением false.
 func (c *Cache) GetById(id string) (model.OrderInfo, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	data, ok := c.data[id]
-	return data, ok
+	elem, ok := c.data[id]
+	if !ok {
+		c.stats.Misses++
+		return model.OrderInfo{}, false
+	}
+
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		c.remove(elem)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return model.OrderInfo{}, false
+	}
+
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return e.order, true
 }
 
 // Сохранение данных заказа в кэше по его ID.
 // Если данные для указанного ID уже присутствуют в кэше, они будут заменены новыми данными.
+// Если после сохранения кэш превышает ограничения, вытесняются давно не использовавшиеся записи.
 func (c *Cache) SetById(id string, data model.OrderInfo) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data[id] = data
+	e := &entry{id: id, order: data, size: OrderSize(data)}
+	if c.opts.ttl > 0 {
+		e.expires = c.now().Add(c.opts.ttl)
+	}
+
+	if elem, ok := c.data[id]; ok {
+		c.stats.Bytes += e.size - elem.Value.(*entry).size
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+	} else {
+		c.data[id] = c.lru.PushFront(e)
+		c.stats.Entries++
+		c.stats.Bytes += e.size
+	}
+
+	for c.overflows() {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Обход всех актуальных записей кэша, начиная с давно не использовавшихся.
+// Обход прекращается, если fn возвращает false. Сохранение записей в том же порядке восстанавливает порядок LRU.
+func (c *Cache) Range(fn func(id string, data model.OrderInfo) bool) {
+	c.mu.Lock()
+	now := c.now()
+	entries := make([]*entry, 0, c.lru.Len())
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*entry)
+		if e.expires.IsZero() || now.Before(e.expires) {
+			entries = append(entries, e)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, e := range entries {
+		if !fn(e.id, e.order) {
+			return
+		}
+	}
+}
+
+// Получение счетчиков работы кэша
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// Проверка превышения ограничений кэша
+func (c *Cache) overflows() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+	return (c.opts.maxEntries > 0 && c.stats.Entries > c.opts.maxEntries) ||
+		(c.opts.maxBytes > 0 && c.stats.Bytes > c.opts.maxBytes)
+}
+
+// Удаление записи из кэша
+func (c *Cache) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.data, e.id)
+	c.stats.Entries--
+	c.stats.Bytes -= e.size
+}
+
+// Приблизительный размер заказа в памяти в байтах: размер структур и содержимого строк и срезов
+func OrderSize(order model.OrderInfo) int64 {
+	v := reflect.ValueOf(order)
+	return int64(v.Type().Size()) + sizeOfContents(v)
+}
+
+// Размер данных, на которые ссылаются строки и срезы внутри значения
+func sizeOfContents(v reflect.Value) int64 {
+	var size int64
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Slice:
+		size += int64(v.Len()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += sizeOfContents(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			size += sizeOfContents(v.Field(i))
+		}
+	}
+	return size
 }
@@ -3,6 +3,8 @@ package cache
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
+	"wbl0/internal/model"
 	"wbl0/testutils"
 )
 
@@ -18,3 +20,74 @@ func TestCache_GetAndSetById(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, testutils.TestOrder, data)
 }
+
+// Вспомогательная функция для создания заказа с заданным идентификатором
+func orderWithId(id string) model.OrderInfo {
+	order := testutils.TestOrder
+	order.OrderUid = id
+	return order
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(WithMaxEntries(2))
+
+	cache.SetById("a", orderWithId("a"))
+	cache.SetById("b", orderWithId("b"))
+
+	//Обращение к "a" делает "b" давно не использовавшимся заказом
+	_, ok := cache.GetById("a")
+	assert.True(t, ok)
+	cache.SetById("c", orderWithId("c"))
+
+	_, ok = cache.GetById("b")
+	assert.False(t, ok)
+	_, ok = cache.GetById("a")
+	assert.True(t, ok)
+	_, ok = cache.GetById("c")
+	assert.True(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	size := OrderSize(orderWithId("a"))
+	cache := NewCache(WithMaxBytes(2*size + size/2))
+
+	cache.SetById("a", orderWithId("a"))
+	cache.SetById("b", orderWithId("b"))
+	cache.SetById("c", orderWithId("c"))
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, 2*size, stats.Bytes)
+	assert.Equal(t, uint64(1), stats.Evictions)
+
+	//Повторное сохранение заказа не меняет размер кэша
+	cache.SetById("c", orderWithId("c"))
+	assert.Equal(t, 2*size, cache.Stats().Bytes)
+}
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Now()
+	cache := NewCache(WithTTL(time.Minute))
+	cache.now = func() time.Time { return now }
+
+	cache.SetById("a", orderWithId("a"))
+
+	now = now.Add(59 * time.Second)
+	_, ok := cache.GetById("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = cache.GetById("a")
+	assert.False(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, int64(0), stats.Bytes)
+}
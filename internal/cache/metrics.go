@@ -0,0 +1,41 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	hitsDesc        = prometheus.NewDesc("wbl0_cache_hits_total", "Количество попаданий в кэш", nil, nil)
+	missesDesc      = prometheus.NewDesc("wbl0_cache_misses_total", "Количество промахов кэша", nil, nil)
+	evictionsDesc   = prometheus.NewDesc("wbl0_cache_evictions_total", "Количество записей, вытесненных из кэша", nil, nil)
+	expirationsDesc = prometheus.NewDesc("wbl0_cache_expirations_total", "Количество записей, удаленных по истечении времени жизни", nil, nil)
+	entriesDesc     = prometheus.NewDesc("wbl0_cache_entries", "Количество записей в кэше", nil, nil)
+	bytesDesc       = prometheus.NewDesc("wbl0_cache_bytes", "Приблизительный размер записей в кэше в байтах", nil, nil)
+)
+
+// Сборщик метрик Prometheus, который при каждом запросе метрик читает счетчики кэша через Stats
+type Collector struct {
+	cache OrderCache
+}
+
+// Создать сборщик метрик для кэша c. Сборщик нужно зарегистрировать, например, через prometheus.MustRegister
+func NewCollector(c OrderCache) *Collector {
+	return &Collector{cache: c}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hitsDesc
+	ch <- missesDesc
+	ch <- evictionsDesc
+	ch <- expirationsDesc
+	ch <- entriesDesc
+	ch <- bytesDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(expirationsDesc, prometheus.CounterValue, float64(stats.Expirations))
+	ch <- prometheus.MustNewConstMetric(entriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCache(WithMaxEntries(1))
+	c.SetById("a", orderWithId("a"))
+	c.SetById("b", orderWithId("b"))
+	c.GetById("a")
+	c.GetById("b")
+
+	expected := `
+# HELP wbl0_cache_entries Количество записей в кэше
+# TYPE wbl0_cache_entries gauge
+wbl0_cache_entries 1
+# HELP wbl0_cache_evictions_total Количество записей, вытесненных из кэша
+# TYPE wbl0_cache_evictions_total counter
+wbl0_cache_evictions_total 1
+# HELP wbl0_cache_hits_total Количество попаданий в кэш
+# TYPE wbl0_cache_hits_total counter
+wbl0_cache_hits_total 1
+# HELP wbl0_cache_misses_total Количество промахов кэша
+# TYPE wbl0_cache_misses_total counter
+wbl0_cache_misses_total 1
+`
+	err := testutil.CollectAndCompare(NewCollector(c), strings.NewReader(expected),
+		"wbl0_cache_entries", "wbl0_cache_evictions_total", "wbl0_cache_hits_total", "wbl0_cache_misses_total")
+	assert.NoError(t, err)
+}
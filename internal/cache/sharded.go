@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Кэш заказов, разделенный на сегменты (шарды) по хэшу идентификатора заказа.
+// Каждый сегмент защищен собственным RWMutex, поэтому чтения не блокируют друг друга,
+// а запись блокирует только один сегмент.
+// Ограничения размера делятся между сегментами поровну. Вместо точного LRU, который требует
+// блокировки на запись при каждом чтении, используется алгоритм "второго шанса":
+// чтение только помечает запись как использованную, а при вытеснении помеченные записи пропускаются один раз.
+type ShardedCache struct {
+	shards      []*shard
+	ttl         time.Duration
+	now         func() time.Time
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// Сегмент кэша
+type shard struct {
+	mu         sync.RWMutex
+	data       map[string]*shardEntry
+	queue      []queueSlot
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+}
+
+// Запись сегмента кэша
+type shardEntry struct {
+	order      model.OrderInfo
+	size       int64
+	expires    time.Time
+	referenced atomic.Bool
+}
+
+// Позиция в очереди вытеснения. Запись e указывается, чтобы отличать позиции удаленных или замененных записей
+// от позиции записи, сохраненной позже с тем же идентификатором
+type queueSlot struct {
+	id string
+	e  *shardEntry
+}
+
+// Создать новый экземпляр кэша из n сегментов и вернуть указатель на него
+func NewShardedCache(n int, opts ...Option) *ShardedCache {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	c := &ShardedCache{
+		shards: make([]*shard, n),
+		ttl:    o.ttl,
+		now:    time.Now,
+	}
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			data:       make(map[string]*shardEntry),
+			maxEntries: int(ceilDiv(int64(o.maxEntries), int64(n))),
+			maxBytes:   ceilDiv(o.maxBytes, int64(n)),
+		}
+	}
+	return c
+}
+
+// Получение данных заказа по его ID.
+// Если данные для указанного ID отсутствуют в кэше или устарели, возвращается второй аргумент со значением false.
+func (c *ShardedCache) GetById(id string) (model.OrderInfo, bool) {
+	s := c.shardFor(id)
+
+	s.mu.RLock()
+	e, ok := s.data[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return model.OrderInfo{}, false
+	}
+
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		s.mu.Lock()
+		//Запись могла быть заменена, пока блокировка была снята
+		if s.data[id] == e {
+			s.remove(id, e)
+			c.expirations.Add(1)
+		}
+		s.mu.Unlock()
+		c.misses.Add(1)
+		return model.OrderInfo{}, false
+	}
+
+	e.referenced.Store(true)
+	c.hits.Add(1)
+	return e.order, true
+}
+
+// Сохранение данных заказа в кэше по его ID.
+// Если данные для указанного ID уже присутствуют в кэше, они будут заменены новыми данными,
+// а запись переместится в конец очереди вытеснения.
+func (c *ShardedCache) SetById(id string, data model.OrderInfo) {
+	e := &shardEntry{order: data, size: OrderSize(data)}
+	if c.ttl > 0 {
+		e.expires = c.now().Add(c.ttl)
+	}
+
+	s := c.shardFor(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.data[id]; ok {
+		s.bytes -= old.size
+	}
+	s.data[id] = e
+	s.bytes += e.size
+	if s.bounded() {
+		//Позиция замененной записи остается в очереди, поэтому при частой замене очередь перестраивается
+		s.queue = append(s.queue, queueSlot{id: id, e: e})
+		s.compact()
+	}
+
+	c.evictions.Add(s.evict())
+}
+
+// Обход всех актуальных записей кэша по сегментам. Обход прекращается, если fn возвращает false
+func (c *ShardedCache) Range(fn func(id string, data model.OrderInfo) bool) {
+	now := c.now()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		ids := make([]string, 0, len(s.data))
+		entries := make([]*shardEntry, 0, len(s.data))
+		for id, e := range s.data {
+			if e.expires.IsZero() || now.Before(e.expires) {
+				ids = append(ids, id)
+				entries = append(entries, e)
+			}
+		}
+		s.mu.RUnlock()
+
+		for i, e := range entries {
+			if !fn(ids[i], e.order) {
+				return
+			}
+		}
+	}
+}
+
+// Получение счетчиков работы кэша
+func (c *ShardedCache) Stats() Stats {
+	stats := Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+	for _, s := range c.shards {
+		s.mu.RLock()
+		stats.Entries += len(s.data)
+		stats.Bytes += s.bytes
+		s.mu.RUnlock()
+	}
+	return stats
+}
+
+// Выбор сегмента по хэшу FNV-1a идентификатора заказа
+func (c *ShardedCache) shardFor(id string) *shard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// Проверка того, что для сегмента заданы ограничения размера
+func (s *shard) bounded() bool {
+	return s.maxEntries > 0 || s.maxBytes > 0
+}
+
+// Вытеснение записей до соблюдения ограничений сегмента. Возвращает количество вытесненных записей
+func (s *shard) evict() uint64 {
+	var evicted uint64
+	for len(s.queue) > 0 && s.overflows() {
+		slot := s.queue[0]
+		s.queue = s.queue[1:]
+
+		if s.data[slot.id] != slot.e {
+			//Запись уже удалена по истечении времени жизни или заменена
+			continue
+		}
+		if slot.e.referenced.CompareAndSwap(true, false) {
+			s.queue = append(s.queue, slot)
+			continue
+		}
+		s.remove(slot.id, slot.e)
+		evicted++
+	}
+	return evicted
+}
+
+// Проверка превышения ограничений сегмента
+func (s *shard) overflows() bool {
+	return (s.maxEntries > 0 && len(s.data) > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+// Удаление записи из сегмента.
+// Позиция записи остается в очереди вытеснения и пропускается при следующем проходе,
+// но если таких позиций накопилось много, очередь перестраивается.
+func (s *shard) remove(id string, e *shardEntry) {
+	delete(s.data, id)
+	s.bytes -= e.size
+	s.compact()
+}
+
+// Перестроение очереди вытеснения без позиций удаленных и замененных записей,
+// если их больше, чем позиций актуальных записей
+func (s *shard) compact() {
+	if len(s.queue) > 2*len(s.data)+16 {
+		queue := make([]queueSlot, 0, len(s.data))
+		for _, slot := range s.queue {
+			if s.data[slot.id] == slot.e {
+				queue = append(queue, slot)
+			}
+		}
+		s.queue = queue
+	}
+}
+
+// Деление с округлением вверх
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
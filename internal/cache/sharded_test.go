@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"wbl0/testutils"
+)
+
+func TestShardedCache_GetAndSetById(t *testing.T) {
+	cache := NewShardedCache(8)
+
+	cache.SetById(testutils.TestOrder.OrderUid, testutils.TestOrder)
+
+	data, ok := cache.GetById(testutils.TestOrder.OrderUid)
+	assert.True(t, ok)
+	assert.Equal(t, testutils.TestOrder, data)
+
+	_, ok = cache.GetById("unknown")
+	assert.False(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, OrderSize(testutils.TestOrder), stats.Bytes)
+}
+
+func TestShardedCache_EvictsUnreferencedFirst(t *testing.T) {
+	//Один сегмент, чтобы порядок вытеснения был детерминированным
+	cache := NewShardedCache(1, WithMaxEntries(2))
+
+	cache.SetById("a", orderWithId("a"))
+	cache.SetById("b", orderWithId("b"))
+
+	//Чтение "a" дает ему второй шанс, поэтому вытесняется "b"
+	_, ok := cache.GetById("a")
+	assert.True(t, ok)
+	cache.SetById("c", orderWithId("c"))
+
+	_, ok = cache.GetById("b")
+	assert.False(t, ok)
+	_, ok = cache.GetById("a")
+	assert.True(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+}
+
+func TestShardedCache_QueueBoundedUnderOverwrites(t *testing.T) {
+	cache := NewShardedCache(1, WithMaxEntries(1000))
+
+	//Повторное сохранение одного заказа не накапливает позиции в очереди вытеснения
+	order := orderWithId("a")
+	for i := 0; i < 100000; i++ {
+		cache.SetById("a", order)
+	}
+	s := cache.shards[0]
+	assert.Len(t, s.data, 1)
+	assert.LessOrEqual(t, len(s.queue), 2*len(s.data)+16)
+
+	//После перестроения очереди первой вытесняется самая старая запись
+	for i := 0; i < 1000; i++ {
+		cache.SetById(strconv.Itoa(i), orderWithId(strconv.Itoa(i)))
+	}
+	_, ok := cache.GetById("a")
+	assert.False(t, ok)
+	_, ok = cache.GetById("999")
+	assert.True(t, ok)
+}
+
+func TestShardedCache_BoundedUnderConcurrentWrites(t *testing.T) {
+	cache := NewShardedCache(4, WithMaxEntries(100))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				id := strconv.Itoa(w) + "-" + strconv.Itoa(i)
+				cache.SetById(id, orderWithId(id))
+				cache.GetById(id)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.Entries, 100)
+	assert.Equal(t, uint64(8000-stats.Entries), stats.Evictions)
+}
+
+func TestShardedCache_TTL(t *testing.T) {
+	now := time.Now()
+	cache := NewShardedCache(2, WithTTL(time.Minute))
+	cache.now = func() time.Time { return now }
+
+	cache.SetById("a", orderWithId("a"))
+
+	now = now.Add(time.Minute)
+	_, ok := cache.GetById("a")
+	assert.False(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestShardedCache_ExpiredEntryDoesNotShortenLifeOfNewOne(t *testing.T) {
+	now := time.Now()
+	cache := NewShardedCache(1, WithMaxEntries(2), WithTTL(time.Minute))
+	cache.now = func() time.Time { return now }
+
+	//"a" истекает и сохраняется заново после "b"
+	cache.SetById("a", orderWithId("a"))
+	now = now.Add(30 * time.Second)
+	cache.SetById("b", orderWithId("b"))
+	now = now.Add(30 * time.Second)
+	_, ok := cache.GetById("a")
+	assert.False(t, ok)
+	cache.SetById("a", orderWithId("a"))
+
+	//Вытесняется самая старая запись "b", а не заново сохраненная "a"
+	cache.SetById("c", orderWithId("c"))
+	_, ok = cache.GetById("a")
+	assert.True(t, ok)
+	_, ok = cache.GetById("b")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+}
+
+// Параллельное чтение заказов из заполненного кэша
+func benchmarkParallelGet(b *testing.B, cache OrderCache) {
+	const n = 10000
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = "order-" + strconv.Itoa(i)
+		cache.SetById(ids[i], orderWithId(ids[i]))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.GetById(ids[i%n])
+			i++
+		}
+	})
+}
+
+// Параллельное чтение с записью каждого десятого заказа, как при одновременной работе HTTP и NATS
+func benchmarkParallelMixed(b *testing.B, cache OrderCache) {
+	const n = 10000
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = "order-" + strconv.Itoa(i)
+		cache.SetById(ids[i], orderWithId(ids[i]))
+	}
+	order := orderWithId("order")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				cache.SetById(ids[i%n], order)
+			} else {
+				cache.GetById(ids[i%n])
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, NewCache())
+}
+
+func BenchmarkShardedCache_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, NewShardedCache(32))
+}
+
+func BenchmarkCache_ParallelMixed(b *testing.B) {
+	benchmarkParallelMixed(b, NewCache())
+}
+
+func BenchmarkShardedCache_ParallelMixed(b *testing.B) {
+	benchmarkParallelMixed(b, NewShardedCache(32))
+}
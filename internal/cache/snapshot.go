@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Версия формата снимка кэша. Снимки другой версии не загружаются
+const snapshotVersion = 3
+
+// Заголовок снимка кэша.
+// Watermark - время по часам БД, полученное перед созданием снимка: после загрузки снимка
+// из БД достаточно дочитать заказы, измененные не раньше этого времени.
+type snapshotHeader struct {
+	Version   int
+	CreatedAt time.Time
+	Watermark time.Time
+	Count     int
+}
+
+// Сохранение всех записей кэша в файл в формате gob.
+// watermark должен быть получен из БД до вызова, чтобы изменения, не попавшие в снимок, были дочитаны при загрузке.
+// Снимок сначала пишется во временный файл, который затем атомарно заменяет предыдущий снимок.
+func SaveSnapshot(c OrderCache, path string, watermark time.Time) error {
+	var orders []model.OrderInfo
+	c.Range(func(_ string, order model.OrderInfo) bool {
+		orders = append(orders, order)
+		return true
+	})
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании снимка кэша: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := gob.NewEncoder(w)
+	err = enc.Encode(snapshotHeader{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		Watermark: watermark,
+		Count:     len(orders),
+	})
+	for i := 0; err == nil && i < len(orders); i++ {
+		err = enc.Encode(&orders[i])
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при записи снимка кэша: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ошибка при сохранении снимка кэша: %w", err)
+	}
+	return nil
+}
+
+// Загрузка записей из снимка в кэш. Возвращает watermark снимка.
+// Если файла снимка нет, возвращается ошибка, для которой errors.Is(err, os.ErrNotExist) == true.
+func LoadSnapshot(c OrderCache, path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ошибка при открытии снимка кэша: %w", err)
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return time.Time{}, fmt.Errorf("ошибка при чтении заголовка снимка кэша: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return time.Time{}, fmt.Errorf("неподдерживаемая версия снимка кэша: %d", header.Version)
+	}
+
+	for i := 0; i < header.Count; i++ {
+		var order model.OrderInfo
+		if err := dec.Decode(&order); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return time.Time{}, fmt.Errorf("ошибка при чтении снимка кэша: %w", err)
+		}
+		c.SetById(order.OrderUid, order)
+	}
+
+	return header.Watermark, nil
+}
+
+// Периодическое сохранение снимков кэша до отмены ctx. Водяной знак каждого снимка возвращает функция watermark.
+// Ошибки сохранения регистрируются в журнале logger
+func RunSnapshots(ctx context.Context, c OrderCache, path string, interval time.Duration, watermark func() (time.Time, error), logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			wm, err := watermark()
+			if err == nil {
+				err = SaveSnapshot(c, path, wm)
+			}
+			if err != nil {
+				logger.Error("Ошибка при сохранении снимка кэша", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Вспомогательная функция для создания заказа с заданными идентификатором и датой создания
+func orderCreatedAt(id, dateCreated string) model.OrderInfo {
+	order := orderWithId(id)
+	order.DateCreated, _ = time.Parse(time.RFC3339, dateCreated)
+	return order
+}
+
+func TestSnapshot_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	source := NewCache()
+	source.SetById("a", orderCreatedAt("a", "2021-11-26T06:22:19Z"))
+	source.SetById("b", orderCreatedAt("b", "2023-01-02T10:00:00Z"))
+	source.SetById("c", orderCreatedAt("c", "2022-05-01T00:00:00Z"))
+	//Водяной знак задается вызывающим и не зависит от дат создания заказов
+	require.NoError(t, SaveSnapshot(source, path, time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)))
+
+	//Снимок может быть загружен в любую реализацию кэша
+	target := NewShardedCache(4)
+	watermark, err := LoadSnapshot(target, path)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC), watermark)
+
+	for _, id := range []string{"a", "b", "c"} {
+		expected, _ := source.GetById(id)
+		actual, ok := target.GetById(id)
+		assert.True(t, ok)
+		assert.Equal(t, expected, actual)
+	}
+	assert.Equal(t, 3, target.Stats().Entries)
+}
+
+func TestSnapshot_PreservesLRUOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	source := NewCache()
+	source.SetById("a", orderWithId("a"))
+	source.SetById("b", orderWithId("b"))
+	source.GetById("a")
+	require.NoError(t, SaveSnapshot(source, path, time.Now()))
+
+	//После загрузки "b" остается давно не использовавшимся заказом и вытесняется первым
+	target := NewCache(WithMaxEntries(2))
+	_, err := LoadSnapshot(target, path)
+	require.NoError(t, err)
+	target.SetById("c", orderWithId("c"))
+
+	_, ok := target.GetById("b")
+	assert.False(t, ok)
+	_, ok = target.GetById("a")
+	assert.True(t, ok)
+}
+
+func TestSnapshot_Missing(t *testing.T) {
+	_, err := LoadSnapshot(NewCache(), filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSnapshot_Truncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	source := NewCache()
+	source.SetById("a", orderWithId("a"))
+	require.NoError(t, SaveSnapshot(source, path, time.Now()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data[:len(data)-10], 0o600))
+
+	_, err = LoadSnapshot(NewCache(), path)
+	assert.Error(t, err)
+}
+
+func TestRunSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewCache()
+	c.SetById("a", orderWithId("a"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSnapshots(ctx, c, path, 10*time.Millisecond, func() (time.Time, error) { return time.Now(), nil }, slog.Default())
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Переменная окружения с путем к файлу конфигурации (альтернатива флагу -config)
+const ConfigPathEnv = "WBL0_CONFIG"
+
+// Конфигурация сервиса.
+// Значения берутся из умолчаний, затем из YAML-файла, затем из переменных окружения и флагов командной строки.
+// Каждый следующий источник переопределяет предыдущий.
+type Config struct {
+	Postgres Postgres `yaml:"postgres"`
+	NATS     NATS     `yaml:"nats"`
+	HTTP     HTTP     `yaml:"http"`
+	Cache    Cache    `yaml:"cache"`
+	Log      Log      `yaml:"log"`
+
+	Validation Validation `yaml:"validation"`
+
+	//Максимальное время плавной остановки сервиса после получения SIGINT/SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"WBL0_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"максимальное время плавной остановки сервиса" required:"true"`
+}
+
+// Параметры подключения к БД PostgreSQL
+type Postgres struct {
+	Host     string `yaml:"host" env:"WBL0_POSTGRES_HOST" flag:"postgres-host" usage:"хост PostgreSQL" required:"true"`
+	Port     string `yaml:"port" env:"WBL0_POSTGRES_PORT" flag:"postgres-port" usage:"порт PostgreSQL" required:"true"`
+	User     string `yaml:"user" env:"WBL0_POSTGRES_USER" flag:"postgres-user" usage:"пользователь PostgreSQL" required:"true"`
+	Password string `yaml:"password" env:"WBL0_POSTGRES_PASSWORD" flag:"postgres-password" usage:"пароль PostgreSQL"`
+	DBName   string `yaml:"dbname" env:"WBL0_POSTGRES_DBNAME" flag:"postgres-dbname" usage:"имя БД PostgreSQL" required:"true"`
+	SSLMode  string `yaml:"sslmode" env:"WBL0_POSTGRES_SSLMODE" flag:"postgres-sslmode" usage:"режим SSL для PostgreSQL"`
+
+	//Политика сохранения заказа, который уже есть в БД: reject, ignore или overwrite-if-newer
+	DuplicatePolicy string `yaml:"duplicate_policy" env:"WBL0_POSTGRES_DUPLICATE_POLICY" flag:"duplicate-policy" usage:"политика сохранения повторных заказов: reject, ignore или overwrite-if-newer" required:"true"`
+}
+
+// Строка подключения к БД PostgreSQL в формате ключ=значение
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		dsnValue(p.Host), dsnValue(p.Port), dsnValue(p.User), dsnValue(p.Password), dsnValue(p.DBName), dsnValue(p.SSLMode))
+}
+
+// Значение параметра строки подключения. Пустые значения и значения с пробелами, кавычками, обратной косой чертой
+// или знаком = заключаются в одинарные кавычки, кавычки и обратная косая черта внутри экранируются
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n'\\=") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// Параметры подключения к NATS
+type NATS struct {
+	URL               string `yaml:"url" env:"WBL0_NATS_URL" flag:"nats-url" usage:"адрес сервера NATS" required:"true"`
+	JetStream         bool   `yaml:"jetstream" env:"WBL0_NATS_JETSTREAM" flag:"jetstream" usage:"получать заказы через durable-консьюмер JetStream"`
+	DeadLetterSubject string `yaml:"dead_letter_subject" env:"WBL0_NATS_DEAD_LETTER_SUBJECT" flag:"dlq-subject" usage:"канал NATS для отклоненных сообщений" required:"true"`
+	//Количество последних отклоненных сообщений, доступных для просмотра и повторной отправки через HTTP
+	DeadLetterCapacity int `yaml:"dead_letter_capacity" env:"WBL0_NATS_DEAD_LETTER_CAPACITY" flag:"dlq-capacity" usage:"количество хранимых отклоненных сообщений"`
+}
+
+// Параметры HTTP-сервера
+type HTTP struct {
+	Addr         string        `yaml:"addr" env:"WBL0_HTTP_ADDR" flag:"http-addr" usage:"адрес, на котором слушает HTTP-сервер" required:"true"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"WBL0_HTTP_READ_TIMEOUT" flag:"http-read-timeout" usage:"таймаут чтения HTTP-запроса"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"WBL0_HTTP_WRITE_TIMEOUT" flag:"http-write-timeout" usage:"таймаут записи HTTP-ответа"`
+}
+
+// Параметры кэша заказов
+type Cache struct {
+	MaxEntries int           `yaml:"max_entries" env:"WBL0_CACHE_MAX_ENTRIES" flag:"cache-max-entries" usage:"максимальное количество заказов в кэше (0 - без ограничения)"`
+	MaxBytes   int64         `yaml:"max_bytes" env:"WBL0_CACHE_MAX_BYTES" flag:"cache-max-bytes" usage:"максимальный размер кэша в байтах (0 - без ограничения)"`
+	TTL        time.Duration `yaml:"ttl" env:"WBL0_CACHE_TTL" flag:"cache-ttl" usage:"время жизни заказа в кэше (0 - без ограничения)"`
+	Shards     int           `yaml:"shards" env:"WBL0_CACHE_SHARDS" flag:"cache-shards" usage:"количество сегментов кэша (0 - несегментированный LRU-кэш)"`
+
+	SnapshotPath     string        `yaml:"snapshot_path" env:"WBL0_CACHE_SNAPSHOT_PATH" flag:"cache-snapshot-path" usage:"файл снимка кэша для быстрого запуска (пусто - снимки отключены)"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env:"WBL0_CACHE_SNAPSHOT_INTERVAL" flag:"cache-snapshot-interval" usage:"периодичность сохранения снимка кэша"`
+}
+
+// Параметры журнала
+type Log struct {
+	Level  string `yaml:"level" env:"WBL0_LOG_LEVEL" flag:"log-level" usage:"уровень журнала: debug, info, warn или error" required:"true"`
+	Format string `yaml:"format" env:"WBL0_LOG_FORMAT" flag:"log-format" usage:"формат журнала: json или text" required:"true"`
+}
+
+// Правила проверки заказов
+type Validation struct {
+	//Отклонение заказов с полями, которых нет в JSON Schema заказа
+	StrictSchema bool `yaml:"strict_schema" env:"WBL0_VALIDATION_STRICT_SCHEMA" flag:"strict-schema" usage:"отклонять заказы с неизвестными полями"`
+
+	//Политики полей по пути из JSON-имен без индексов массивов, например delivery.name или items.rid.
+	//Заданные параметры заменяют умолчания поля, незаданные остаются прежними
+	Fields map[string]FieldPolicy `yaml:"fields"`
+}
+
+// Политика строкового поля заказа
+type FieldPolicy struct {
+	//Набор допустимых символов: printable, ascii, identifier, alnum или digits
+	Charset string `yaml:"charset"`
+	//Максимальная длина в символах, не больше размера столбца в БД
+	MaxLength int `yaml:"max_length"`
+	//Нормализации, применяемые перед проверкой: trim, collapse_spaces, lower, upper
+	Normalize []string `yaml:"normalize"`
+}
+
+// Политики полей заказа в виде, который принимает model.SetFieldPolicies
+func (v Validation) FieldPolicies() map[string]model.FieldPolicy {
+	policies := make(map[string]model.FieldPolicy, len(v.Fields))
+	for path, p := range v.Fields {
+		policies[path] = p.model()
+	}
+	return policies
+}
+
+// Политика поля в виде модели
+func (p FieldPolicy) model() model.FieldPolicy {
+	return model.FieldPolicy{Charset: p.Charset, MaxLength: p.MaxLength, Normalize: p.Normalize}
+}
+
+// Конфигурация по умолчанию для локального запуска
+func Default() Config {
+	return Config{
+		Postgres: Postgres{
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "postgres",
+			Password: "postgres",
+			DBName:   "wbl0",
+			SSLMode:  "disable",
+
+			DuplicatePolicy: "ignore",
+		},
+		NATS: NATS{
+			URL:                "nats://localhost:4222",
+			DeadLetterSubject:  "order_info.dlq",
+			DeadLetterCapacity: 1000,
+		},
+		HTTP: HTTP{
+			Addr:         ":8080",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+		Cache: Cache{
+			MaxEntries:       100000,
+			SnapshotInterval: 5 * time.Minute,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// Загрузка конфигурации из файла, переменных окружения и аргументов командной строки args (без имени программы).
+// Путь к файлу задается флагом -config или переменной окружения WBL0_CONFIG; если он не задан, файл не читается.
+// Аргументы, оставшиеся после флагов, не допускаются.
+func Load(args []string) (Config, error) {
+	cfg, rest, err := Parse(args)
+	if err == nil && len(rest) > 0 {
+		return Config{}, fmt.Errorf("неожиданные аргументы командной строки: %q", rest)
+	}
+	return cfg, err
+}
+
+// Загрузка конфигурации так же, как в Load. Дополнительно возвращает аргументы, оставшиеся после флагов,
+// например подкоманду и ее параметры.
+func Parse(args []string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("wbl0", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(ConfigPathEnv), "путь к YAML-файлу конфигурации")
+	flags := map[string]*flagValue{}
+	walk(reflect.ValueOf(&cfg).Elem(), "", func(field reflect.StructField, _ reflect.Value, _ string) {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			return
+		}
+		flags[name] = &flagValue{isBool: field.Type.Kind() == reflect.Bool}
+		fs.Var(flags[name], name, field.Tag.Get("usage"))
+	})
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(&cfg, *path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	var errs []error
+	walk(reflect.ValueOf(&cfg).Elem(), "", func(field reflect.StructField, value reflect.Value, key string) {
+		if name := field.Tag.Get("env"); name != "" {
+			if raw, ok := os.LookupEnv(name); ok {
+				if err := setValue(value, raw); err != nil {
+					errs = append(errs, fmt.Errorf("переменная окружения %s: %w", name, err))
+				}
+			}
+		}
+		if f, ok := flags[field.Tag.Get("flag")]; ok && f.set {
+			if err := setValue(value, f.raw); err != nil {
+				errs = append(errs, fmt.Errorf("флаг -%s: %w", field.Tag.Get("flag"), err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, nil, err
+	}
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+// Чтение конфигурации из YAML-файла поверх текущих значений
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла конфигурации: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("ошибка при разборе файла конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+// Проверка того, что заданы все обязательные параметры
+func (c Config) Validate() error {
+	var missing []string
+	walk(reflect.ValueOf(&c).Elem(), "", func(field reflect.StructField, value reflect.Value, key string) {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			missing = append(missing, key)
+		}
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("не заданы обязательные параметры конфигурации: %s", strings.Join(missing, ", "))
+	}
+	if c.NATS.DeadLetterCapacity <= 0 {
+		return errors.New("nats.dead_letter_capacity должен быть больше нуля")
+	}
+	if c.Cache.SnapshotPath != "" && c.Cache.SnapshotInterval <= 0 {
+		return errors.New("cache.snapshot_interval должен быть больше нуля")
+	}
+	switch c.Postgres.DuplicatePolicy {
+	case "reject", "ignore", "overwrite-if-newer":
+	default:
+		return fmt.Errorf("недопустимая политика сохранения повторных заказов postgres.duplicate_policy: %q", c.Postgres.DuplicatePolicy)
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("недопустимый уровень журнала log.level: %q", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		return fmt.Errorf("недопустимый формат журнала log.format: %q", c.Log.Format)
+	}
+	for path, p := range c.Validation.Fields {
+		if err := model.CheckFieldPolicy(path, p.model()); err != nil {
+			return fmt.Errorf("недопустимая политика поля validation.fields.%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Обход всех конечных полей конфигурации. key - путь к полю в YAML-файле, например postgres.host
+func walk(v reflect.Value, prefix string, fn func(field reflect.StructField, value reflect.Value, key string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), key, fn)
+			continue
+		}
+		fn(field, v.Field(i), key)
+	}
+}
+
+// Установка значения поля конфигурации из строки
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+// Значение флага командной строки. Запоминает, был ли флаг задан, чтобы переопределять только явно указанные параметры
+type flagValue struct {
+	raw    string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	return f.raw
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw = raw
+	f.set = true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, "host=localhost port=5432 user=postgres password=postgres dbname=wbl0 sslmode=disable", cfg.Postgres.DSN())
+}
+
+func TestPostgres_DSNEscaping(t *testing.T) {
+	p := Default().Postgres
+	p.Password = `p a's\=`
+	p.DBName = ""
+	assert.Equal(t, `host=localhost port=5432 user=postgres password='p a\'s\\=' dbname='' sslmode=disable`, p.DSN())
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	//Файл конфигурации переопределяет значения по умолчанию
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+postgres:
+  host: db.file
+  dbname: orders
+nats:
+  url: nats://nats.file:4222
+http:
+  addr: ":9000"
+  read_timeout: 3s
+`), 0o600)
+	require.NoError(t, err)
+	t.Setenv(ConfigPathEnv, path)
+
+	//Переменные окружения переопределяют файл, а флаги - переменные окружения
+	t.Setenv("WBL0_POSTGRES_HOST", "db.env")
+	t.Setenv("WBL0_NATS_URL", "nats://nats.env:4222")
+
+	cfg, err := Load([]string{"-nats-url", "nats://nats.flag:4222", "-jetstream"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "db.env", cfg.Postgres.Host)
+	assert.Equal(t, "orders", cfg.Postgres.DBName)
+	assert.Equal(t, "5432", cfg.Postgres.Port)
+	assert.Equal(t, "nats://nats.flag:4222", cfg.NATS.URL)
+	assert.True(t, cfg.NATS.JetStream)
+	assert.Equal(t, ":9000", cfg.HTTP.Addr)
+	assert.Equal(t, 3*time.Second, cfg.HTTP.ReadTimeout)
+}
+
+func TestLoad_RejectsPositionalArgs(t *testing.T) {
+	_, err := Load([]string{"-jetstream", "migrate", "up"})
+	assert.ErrorContains(t, err, `"migrate" "up"`)
+
+	//Parse возвращает оставшиеся аргументы, в том числе подкоманду, указанную после флагов
+	cfg, rest, err := Parse([]string{"-jetstream", "migrate", "up"})
+	require.NoError(t, err)
+	assert.True(t, cfg.NATS.JetStream)
+	assert.Equal(t, []string{"migrate", "up"}, rest)
+}
+
+func TestLoad_RequiredFields(t *testing.T) {
+	t.Setenv("WBL0_POSTGRES_HOST", "")
+
+	_, err := Load([]string{"-http-addr", ""})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "postgres.host")
+	assert.Contains(t, err.Error(), "http.addr")
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	t.Setenv("WBL0_HTTP_READ_TIMEOUT", "soon")
+
+	_, err := Load(nil)
+	assert.ErrorContains(t, err, "WBL0_HTTP_READ_TIMEOUT")
+}
+
+func TestLoad_DeadLetterCapacity(t *testing.T) {
+	cfg, err := Load([]string{"-dlq-capacity", "50"})
+	require.NoError(t, err)
+	assert.Equal(t, 50, cfg.NATS.DeadLetterCapacity)
+
+	t.Setenv("WBL0_NATS_DEAD_LETTER_CAPACITY", "0")
+	_, err = Load(nil)
+	assert.ErrorContains(t, err, "nats.dead_letter_capacity")
+}
+
+func TestLoad_InvalidLogLevel(t *testing.T) {
+	_, err := Load([]string{"-log-level", "verbose"})
+	assert.ErrorContains(t, err, "log.level")
+}
+
+func TestLoad_FieldPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+validation:
+  fields:
+    delivery.zip:
+      charset: digits
+    items.name:
+      max_length: 60
+      normalize: [trim, upper]
+`), 0o600)
+	require.NoError(t, err)
+
+	cfg, err := Load([]string{"-config", path})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]FieldPolicy{
+		"delivery.zip": {Charset: "digits"},
+		"items.name":   {MaxLength: 60, Normalize: []string{"trim", "upper"}},
+	}, cfg.Validation.Fields)
+
+	err = os.WriteFile(path, []byte("validation:\n  fields:\n    delivery.zip:\n      charset: latin\n"), 0o600)
+	require.NoError(t, err)
+	_, err = Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "validation.fields.delivery.zip")
+	assert.ErrorContains(t, err, "latin")
+
+	//Длина не может превышать размер столбца в БД
+	err = os.WriteFile(path, []byte("validation:\n  fields:\n    delivery.zip:\n      max_length: 11\n"), 0o600)
+	require.NoError(t, err)
+	_, err = Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "validation.fields.delivery.zip")
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Действие журнала аудита для результата сохранения
+func (r SaveResult) auditAction() string {
+	switch r {
+	case Updated:
+		return model.AuditUpdated
+	case Skipped:
+		return model.AuditSkipped
+	}
+	return model.AuditInserted
+}
+
+// Запись действия action в журнал аудита в рамках транзакции сохранения заказа
+func writeAudit(tx *sql.Tx, orderUid string, src model.Source, action string) error {
+	_, err := tx.Exec("insert into order_audit (order_uid, subject, sequence, received_at, payload_hash, action) values ($1, $2, $3, $4, $5, $6)",
+		orderUid, src.Subject, int64(src.Sequence), receivedAt(src), src.PayloadHash, action)
+	return err
+}
+
+// Время получения заказа для журнала аудита. Если оно не задано, используется текущее время
+func receivedAt(src model.Source) time.Time {
+	if src.ReceivedAt.IsZero() {
+		return time.Now()
+	}
+	return src.ReceivedAt
+}
+
+// История получения заказа из журнала аудита в порядке получения.
+// Если заказа нет в БД, возвращается ErrNotFound.
+func (pg *PostgresDB) OrderHistory(orderUid string) ([]model.AuditEntry, error) {
+	rows, err := pg.db.Query("select order_uid, subject, sequence, received_at, payload_hash, action, recorded_at from order_audit where order_uid = $1 order by recorded_at, id", orderUid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []model.AuditEntry{}
+	for rows.Next() {
+		var e model.AuditEntry
+		var sequence int64
+		if err := rows.Scan(&e.OrderUid, &e.Subject, &sequence, &e.ReceivedAt, &e.PayloadHash, &e.Action, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		e.Sequence = uint64(sequence)
+		history = append(history, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(history) == 0 {
+		//Заказы, сохраненные до появления журнала аудита, не имеют записей
+		var exists bool
+		err := pg.db.QueryRow("select exists (select 1 from order_info where order_uid = $1)", orderUid).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Максимальное число параметров одного запроса PostgreSQL
+const maxQueryParams = 65535
+
+// Столбцы таблиц заказа в порядке значений, которые передаются в многострочные запросы
+var (
+	orderColumns    = []string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard"}
+	deliveryColumns = []string{"order_uid", "name", "phone", "zip", "city", "address", "region", "email"}
+	paymentColumns  = []string{"order_uid", "transaction", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}
+	itemColumns     = []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}
+	auditColumns    = []string{"order_uid", "subject", "sequence", "received_at", "payload_hash", "action"}
+)
+
+// Результат сохранения заказа из пакета: Result для сохраненного или пропущенного заказа, иначе ошибка Err.
+// Err - ErrDuplicate или ошибка в данных заказа, которая повторится при повторном сохранении
+type BatchResult struct {
+	Result SaveResult
+	Err    error
+}
+
+// Сохранение пакета заказов в одной транзакции. srcs - источники заказов в порядке orders.
+// Новые заказы вставляются многострочными insert в каждую таблицу (COPY не поддерживает on conflict).
+// Повторные заказы, в том числе повторяющиеся внутри пакета, сохраняются по одному с учетом политики сохранения повторных заказов,
+// отклоненные повторные заказы записываются в журнал аудита.
+// Если многострочная вставка завершилась ошибкой, все заказы сохраняются по одному, каждый в своей точке сохранения,
+// поэтому ошибка в данных одного заказа не отменяет сохранение остальных.
+// Результаты возвращаются в порядке orders. Ошибка возвращается, если пакет не удалось сохранить целиком,
+// в том числе если заказ не сохранен из-за ошибки, которая может не повториться: тогда весь пакет откатывается.
+func (pg *PostgresDB) SaveBatch(orders []model.OrderInfo, srcs []model.Source) (results []BatchResult, err error) {
+	defer observeSave(time.Now(), &err)
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	results = make([]BatchResult, len(orders))
+	bulkErr, err := withSavepoint(tx, "batch_insert", func() error {
+		return insertNewOrders(tx, orders, srcs, results)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bulkErr != nil {
+		pg.logger.Warn("Ошибка при пакетной вставке заказов, заказы сохраняются по одному", "orders", len(orders), "error", bulkErr)
+		results = make([]BatchResult, len(orders))
+	}
+
+	for i, order := range orders {
+		if results[i].Result != 0 {
+			continue
+		}
+		src := srcs[i]
+		var result SaveResult
+		orderErr, err := withSavepoint(tx, "batch_order", func() error {
+			var err error
+			result, err = saveOrder(tx, order, pg.duplicates)
+			if err == nil {
+				err = writeAudit(tx, order.OrderUid, src, result.auditAction())
+			}
+			return err
+		})
+		if err == nil && errors.Is(orderErr, ErrDuplicate) {
+			err = writeAudit(tx, order.OrderUid, src, model.AuditRejected)
+		}
+		if err == nil && orderErr != nil && !permanentError(orderErr) {
+			//Пакет сохраняется заново при повторной доставке, поэтому остальные заказы тоже не сохраняются
+			err = orderErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if orderErr != nil {
+			results[i] = BatchResult{Err: orderErr}
+			continue
+		}
+		results[i] = BatchResult{Result: result}
+	}
+
+	return results, tx.Commit()
+}
+
+// Выполнение fn внутри точки сохранения name.
+// Если fn возвращает ошибку, ее изменения откатываются, а транзакция tx остается пригодной для дальнейшей работы.
+// Первым значением возвращается ошибка fn, вторым - ошибка работы с точкой сохранения.
+func withSavepoint(tx *sql.Tx, name string, fn func() error) (fnErr error, err error) {
+	if _, err := tx.Exec("savepoint " + name); err != nil {
+		return nil, err
+	}
+	if fnErr = fn(); fnErr != nil {
+		_, err = tx.Exec("rollback to savepoint " + name)
+		return fnErr, err
+	}
+	_, err = tx.Exec("release savepoint " + name)
+	return nil, err
+}
+
+// Проверка того, что ошибка сохранения заказа повторится при повторной попытке:
+// повторный заказ, ошибка в данных или нарушение ограничения целостности
+func permanentError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		class := pqErr.Code.Class()
+		return class == "22" || class == "23"
+	}
+	return errors.Is(err, ErrDuplicate)
+}
+
+// Вставка заказов пакета, order_uid которых еще нет в БД, многострочными запросами.
+// Для вставленных заказов в results записывается Inserted, остальные заказы пропускаются.
+// Если order_uid повторяется в пакете, вставляется первый заказ с этим order_uid.
+func insertNewOrders(tx *sql.Tx, orders []model.OrderInfo, srcs []model.Source, results []BatchResult) error {
+	first := make(map[string]int, len(orders))
+	rows := make([][]any, 0, len(orders))
+	for i, o := range orders {
+		if _, ok := first[o.OrderUid]; ok {
+			continue
+		}
+		first[o.OrderUid] = i
+		rows = append(rows, []any{o.OrderUid, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerId, o.DeliveryService, o.Shardkey, o.SmId, o.DateCreated, o.OofShard})
+	}
+
+	var inserted []int
+	err := insertRows(tx, "order_info", orderColumns, "on conflict (order_uid) do nothing returning order_uid", rows, func(r *sql.Rows) error {
+		var uid string
+		if err := r.Scan(&uid); err != nil {
+			return err
+		}
+		inserted = append(inserted, first[uid])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries, payments, items, audit [][]any
+	for _, i := range inserted {
+		o, src := orders[i], srcs[i]
+		d, p := o.Delivery, o.Payment
+		deliveries = append(deliveries, []any{o.OrderUid, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email})
+		payments = append(payments, []any{o.OrderUid, p.Transaction, p.RequestId, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee})
+		for _, it := range o.Items {
+			items = append(items, []any{o.OrderUid, it.ChrtId, it.TrackNumber, it.Price, it.Rid, it.Name, it.Sale, it.Size, it.TotalPrice, it.NmId, it.Brand, it.Status})
+		}
+		audit = append(audit, []any{o.OrderUid, src.Subject, int64(src.Sequence), receivedAt(src), src.PayloadHash, model.AuditInserted})
+	}
+
+	for _, t := range []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{
+		{"deliveries", deliveryColumns, deliveries},
+		{"payments", paymentColumns, payments},
+		{"items", itemColumns, items},
+		{"order_audit", auditColumns, audit},
+	} {
+		if err := insertRows(tx, t.table, t.columns, "", t.rows, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range inserted {
+		results[i].Result = Inserted
+	}
+	return nil
+}
+
+// Вставка строк rows в таблицу table запросами insert ... values (...), (...) suffix.
+// Строки делятся на части так, чтобы число параметров запроса не превышало ограничение PostgreSQL.
+// Если задана функция scan, она вызывается для каждой строки, возвращенной returning в suffix.
+func insertRows(tx *sql.Tx, table string, columns []string, suffix string, rows [][]any, scan func(r *sql.Rows) error) error {
+	perQuery := maxQueryParams / len(columns)
+	for len(rows) > 0 {
+		chunk := rows[:min(perQuery, len(rows))]
+		rows = rows[len(chunk):]
+
+		var query strings.Builder
+		args := make([]any, 0, len(chunk)*len(columns))
+		fmt.Fprintf(&query, "insert into %s (%s) values ", table, strings.Join(columns, ", "))
+		for i, row := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					query.WriteString(", ")
+				}
+				args = append(args, v)
+				fmt.Fprintf(&query, "$%d", len(args))
+			}
+			query.WriteByte(')')
+		}
+		if suffix != "" {
+			query.WriteString(" " + suffix)
+		}
+
+		if err := queryRows(tx, query.String(), args, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Выполнение запроса query в транзакции tx с передачей возвращенных строк в scan
+func queryRows(tx *sql.Tx, query string, args []any, scan func(r *sql.Rows) error) error {
+	if scan == nil {
+		_, err := tx.Exec(query, args...)
+		return err
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"wbl0/internal/model"
+	"wbl0/testutils"
+)
+
+// Копии тестового заказа с идентификаторами batch0, batch1, ...
+func batchOrders(n int) []model.OrderInfo {
+	orders := make([]model.OrderInfo, n)
+	for i := range orders {
+		orders[i] = testutils.TestOrder
+		orders[i].OrderUid = "batch" + strconv.Itoa(i)
+		orders[i].Items = append([]model.Item(nil), testutils.TestOrder.Items...)
+	}
+	return orders
+}
+
+func TestPostgresDB_SaveBatch(t *testing.T) {
+	//Подготовка к тестированию БД
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	pgDB := NewDB(db, WithDuplicatePolicy(DuplicateReject))
+
+	_, err := pgDB.SaveData(testutils.TestOrder, model.Source{})
+	require.NoError(t, err)
+
+	//Новые заказы вставляются пакетом, уже сохраненный и повторяющийся в пакете заказы отклоняются по политике reject
+	orders := append(batchOrders(3), testutils.TestOrder, batchOrders(1)[0])
+	srcs := make([]model.Source, len(orders))
+	for i := range srcs {
+		srcs[i] = model.Source{Subject: "order_info", PayloadHash: "hash" + strconv.Itoa(i)}
+	}
+	results, err := pgDB.SaveBatch(orders, srcs)
+	require.NoError(t, err)
+	require.Len(t, results, len(orders))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, BatchResult{Result: Inserted}, results[i])
+		data, err := pgDB.GetDataById(orders[i].OrderUid)
+		assert.NoError(t, err)
+		assert.Equal(t, orders[i], data)
+	}
+	assert.ErrorIs(t, results[3].Err, ErrDuplicate)
+	assert.ErrorIs(t, results[4].Err, ErrDuplicate)
+
+	//Заказы пакета записываются в журнал аудита с хэшем каждого заказа
+	for i := 1; i < 3; i++ {
+		history, err := pgDB.OrderHistory(orders[i].OrderUid)
+		assert.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, model.AuditInserted, history[0].Action)
+		assert.Equal(t, srcs[i].PayloadHash, history[0].PayloadHash)
+	}
+
+	//Повторение заказа в пакете записывается как отклоненное со своим хэшем
+	history, err := pgDB.OrderHistory(orders[0].OrderUid)
+	assert.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []string{model.AuditInserted, model.AuditRejected}, []string{history[0].Action, history[1].Action})
+	assert.Equal(t, []string{srcs[0].PayloadHash, srcs[4].PayloadHash}, []string{history[0].PayloadHash, history[1].PayloadHash})
+
+	//Повторный заказ в пакете сохраняется по политике overwrite-if-newer
+	newer := orders[0]
+	newer.DateCreated = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	results, err = NewDB(db, WithDuplicatePolicy(DuplicateOverwriteIfNewer)).SaveBatch([]model.OrderInfo{newer}, make([]model.Source, 1))
+	require.NoError(t, err)
+	assert.Equal(t, []BatchResult{{Result: Updated}}, results)
+}
+
+func TestPostgresDB_SaveBatchFallback(t *testing.T) {
+	//Подготовка к тестированию БД
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	pgDB := NewDB(db)
+
+	//Заказ с именем длиннее столбца прерывает многострочную вставку, остальные заказы сохраняются по одному
+	orders := batchOrders(3)
+	orders[1].Delivery.Name = strings.Repeat("a", 200)
+	results, err := pgDB.SaveBatch(orders, make([]model.Source, len(orders)))
+	require.NoError(t, err)
+	assert.Equal(t, BatchResult{Result: Inserted}, results[0])
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, BatchResult{Result: Inserted}, results[2])
+
+	_, err = pgDB.GetDataById(orders[1].OrderUid)
+	assert.ErrorIs(t, err, ErrNotFound)
+	data, err := pgDB.GetDataById(orders[2].OrderUid)
+	assert.NoError(t, err)
+	assert.Equal(t, orders[2], data)
+}
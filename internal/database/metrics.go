@@ -0,0 +1,23 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+// Длительность сохранения заказа в БД по результату: ok или error
+var saveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "wbl0_db_save_duration_seconds",
+	Help:    "Длительность сохранения заказа в БД",
+	Buckets: prometheus.DefBuckets,
+}, []string{"result"})
+
+// Учет длительности сохранения заказа, начатого в момент start и завершенного с ошибкой *err
+func observeSave(start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	saveDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,241 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Файлы миграций вида NNNN_имя.up.sql и NNNN_имя.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// Ключ advisory-блокировки PostgreSQL, которая не дает двум экземплярам сервиса применять миграции одновременно
+const lockKey = 20231001
+
+// Миграция схемы БД
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// Состояние миграции. AppliedAt равно нулю, если миграция еще не применена
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Проверка того, что миграция применена
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// Список встроенных миграций в порядке возрастания версий
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("некорректное имя файла миграции %s", name)
+		}
+		rawVersion, title, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(rawVersion, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("некорректная версия в имени файла миграции %s", name)
+		}
+
+		data, err := fs.ReadFile(files, path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("для миграции %04d нет up- или down-файла", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Применение всех еще не примененных миграций. Возвращает количество примененных миграций.
+// Каждая миграция применяется в отдельной транзакции вместе с записью в schema_migrations.
+func Up(db *sql.DB) (int, error) {
+	migrations, err := All()
+	if err != nil {
+		return 0, err
+	}
+	if err := createTable(db); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ok, err := inTx(db, func(tx *sql.Tx) (bool, error) {
+			var exists bool
+			err := tx.QueryRow("select exists (select 1 from schema_migrations where version = $1)", m.Version).Scan(&exists)
+			if err != nil || exists {
+				return false, err
+			}
+			if _, err := tx.Exec(m.up); err != nil {
+				return false, err
+			}
+			_, err = tx.Exec("insert into schema_migrations (version, name) values ($1, $2)", m.Version, m.Name)
+			return err == nil, err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("ошибка при применении миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// Откат последних steps примененных миграций. Возвращает количество откаченных миграций
+func Down(db *sql.DB, steps int) (int, error) {
+	migrations, err := All()
+	if err != nil {
+		return 0, err
+	}
+	if err := createTable(db); err != nil {
+		return 0, err
+	}
+
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	rolledBack := 0
+	for rolledBack < steps {
+		ok, err := inTx(db, func(tx *sql.Tx) (bool, error) {
+			var version int64
+			err := tx.QueryRow("select version from schema_migrations order by version desc limit 1").Scan(&version)
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+
+			m, ok := byVersion[version]
+			if !ok {
+				return false, fmt.Errorf("миграция %04d применена, но отсутствует в сборке", version)
+			}
+			if _, err := tx.Exec(m.down); err != nil {
+				return false, fmt.Errorf("ошибка при откате миграции %04d_%s: %w", m.Version, m.Name, err)
+			}
+			_, err = tx.Exec("delete from schema_migrations where version = $1", version)
+			return err == nil, err
+		})
+		if err != nil {
+			return rolledBack, err
+		}
+		if !ok {
+			break
+		}
+		rolledBack++
+	}
+	return rolledBack, nil
+}
+
+// Состояние всех встроенных миграций
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := createTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: appliedAt[m.Version]}
+	}
+	return statuses, nil
+}
+
+// Создание таблицы примененных миграций, если она еще не создана
+func createTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// Выполнение fn в транзакции под advisory-блокировкой. Транзакция фиксируется, только если fn вернула true
+func inTx(db *sql.DB, fn func(tx *sql.Tx) (bool, error)) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("select pg_advisory_xact_lock($1)", lockKey); err != nil {
+		return false, err
+	}
+	ok, err := fn(tx)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,64 @@
+package migrations_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/internal/database/migrations"
+	"wbl0/testutils"
+)
+
+// Тест разбора встроенных файлов миграций
+func TestAll(t *testing.T) {
+	all, err := migrations.All()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for i, m := range all {
+		assert.Equal(t, int64(i+1), m.Version, "версии миграций должны идти подряд")
+		assert.NotEmpty(t, m.Name)
+	}
+}
+
+// Тест применения, отката и состояния миграций
+func TestUpDownStatus(t *testing.T) {
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+
+	all, err := migrations.All()
+	require.NoError(t, err)
+
+	//InitTestDatabase уже применил все миграции, повторный запуск ничего не меняет
+	applied, err := migrations.Up(db)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+
+	statuses, err := migrations.Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(all))
+	for _, s := range statuses {
+		assert.True(t, s.Applied(), "миграция %04d должна быть применена", s.Version)
+	}
+
+	rolledBack, err := migrations.Down(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rolledBack)
+
+	statuses, err = migrations.Status(db)
+	require.NoError(t, err)
+	assert.False(t, statuses[len(statuses)-1].Applied())
+	assert.True(t, statuses[0].Applied())
+
+	//Откат большего числа миграций, чем применено, останавливается на пустой схеме
+	rolledBack, err = migrations.Down(db, len(all)+1)
+	require.NoError(t, err)
+	assert.Equal(t, len(all)-1, rolledBack)
+
+	var exists bool
+	require.NoError(t, db.QueryRow("select to_regclass('order_info') is not null").Scan(&exists))
+	assert.False(t, exists)
+
+	applied, err = migrations.Up(db)
+	require.NoError(t, err)
+	assert.Equal(t, len(all), applied)
+}
@@ -1,14 +1,4 @@
-package database
-
-import (
-	"database/sql"
-	"fmt"
-)
-
-// Создание таблиц в БД, если они еще не созданы.
-func CreateTables(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS order_info (
+CREATE TABLE IF NOT EXISTS order_info (
 	order_uid VARCHAR(50) PRIMARY KEY,
 	track_number VARCHAR(50) NOT NULL,
 	entry VARCHAR(50) NOT NULL,
@@ -48,7 +38,7 @@ CREATE TABLE IF NOT EXISTS payments (
 );
 
 CREATE TABLE IF NOT EXISTS items (
-    order_uid VARCHAR(50) REFERENCES order_info(order_uid),
+	order_uid VARCHAR(50) REFERENCES order_info(order_uid),
 	chrt_id INT NOT NULL,
 	track_number VARCHAR(50) NOT NULL,
 	price INT NOT NULL,
@@ -60,12 +50,5 @@ CREATE TABLE IF NOT EXISTS items (
 	nm_id INT NOT NULL,
 	brand VARCHAR(100) NOT NULL,
 	status INT NOT NULL,
-    PRIMARY KEY (order_uid, chrt_id)
-);`
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("ошибка при создании таблиц в базе данных: %w", err)
-	}
-
-	return nil
-}
+	PRIMARY KEY (order_uid, chrt_id)
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS items_nm_id_idx;
+DROP INDEX IF EXISTS items_rid_idx;
+DROP INDEX IF EXISTS order_info_date_created_idx;
+DROP INDEX IF EXISTS order_info_delivery_service_idx;
+DROP INDEX IF EXISTS order_info_track_number_idx;
+DROP INDEX IF EXISTS order_info_customer_id_idx;
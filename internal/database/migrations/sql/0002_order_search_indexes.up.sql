@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS order_info_customer_id_idx ON order_info (customer_id);
+CREATE INDEX IF NOT EXISTS order_info_track_number_idx ON order_info (track_number);
+CREATE INDEX IF NOT EXISTS order_info_delivery_service_idx ON order_info (delivery_service);
+CREATE INDEX IF NOT EXISTS order_info_date_created_idx ON order_info (date_created);
+CREATE INDEX IF NOT EXISTS items_rid_idx ON items (rid);
+CREATE INDEX IF NOT EXISTS items_nm_id_idx ON items (nm_id);
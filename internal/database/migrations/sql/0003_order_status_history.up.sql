@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id BIGSERIAL PRIMARY KEY,
+	order_uid VARCHAR(50) NOT NULL REFERENCES order_info(order_uid),
+	chrt_id INT NOT NULL,
+	old_status INT NOT NULL,
+	new_status INT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_uid_idx ON order_status_history (order_uid, changed_at);
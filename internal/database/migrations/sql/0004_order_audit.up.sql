@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS order_audit (
+	id BIGSERIAL PRIMARY KEY,
+	order_uid VARCHAR(50) NOT NULL REFERENCES order_info(order_uid),
+	subject VARCHAR(100) NOT NULL,
+	sequence BIGINT NOT NULL,
+	received_at TIMESTAMPTZ NOT NULL,
+	payload_hash VARCHAR(64) NOT NULL,
+	action VARCHAR(20) NOT NULL,
+	recorded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_audit_order_uid_idx ON order_audit (order_uid, recorded_at);
@@ -0,0 +1,18 @@
+ALTER TABLE order_status_history
+	ALTER COLUMN chrt_id TYPE INT;
+
+ALTER TABLE items
+	ALTER COLUMN chrt_id TYPE INT,
+	ALTER COLUMN price TYPE INT,
+	ALTER COLUMN total_price TYPE INT,
+	ALTER COLUMN nm_id TYPE INT;
+
+ALTER TABLE payments
+	ALTER COLUMN amount TYPE INT,
+	ALTER COLUMN payment_dt TYPE INT,
+	ALTER COLUMN delivery_cost TYPE INT,
+	ALTER COLUMN goods_total TYPE INT,
+	ALTER COLUMN custom_fee TYPE INT;
+
+ALTER TABLE order_info
+	ALTER COLUMN date_created TYPE VARCHAR(50) USING to_char(date_created AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
@@ -0,0 +1,22 @@
+-- Строки без часового пояса читаются как UTC независимо от настроек сессии, явно указанное смещение сохраняется.
+-- Миграция выполняется в транзакции, поэтому SET LOCAL не влияет на другие запросы
+SET LOCAL timezone = 'UTC';
+
+ALTER TABLE order_info
+	ALTER COLUMN date_created TYPE TIMESTAMPTZ USING date_created::timestamptz;
+
+ALTER TABLE payments
+	ALTER COLUMN amount TYPE BIGINT,
+	ALTER COLUMN payment_dt TYPE BIGINT,
+	ALTER COLUMN delivery_cost TYPE BIGINT,
+	ALTER COLUMN goods_total TYPE BIGINT,
+	ALTER COLUMN custom_fee TYPE BIGINT;
+
+ALTER TABLE items
+	ALTER COLUMN chrt_id TYPE BIGINT,
+	ALTER COLUMN price TYPE BIGINT,
+	ALTER COLUMN total_price TYPE BIGINT,
+	ALTER COLUMN nm_id TYPE BIGINT;
+
+ALTER TABLE order_status_history
+	ALTER COLUMN chrt_id TYPE BIGINT;
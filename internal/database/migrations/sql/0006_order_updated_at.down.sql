@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS order_info_updated_at_idx;
+
+ALTER TABLE order_info
+	DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,6 @@
+-- Время последнего изменения заказа по часам БД: сохранение, замена новой версией или изменение статуса товаров.
+-- Используется как водяной знак при сверке снимка кэша с БД
+ALTER TABLE order_info
+	ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS order_info_updated_at_idx ON order_info (updated_at);
@@ -2,299 +2,425 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
-	"log"
+	"github.com/lib/pq"
+	"log/slog"
+	"strings"
+	"time"
+	"wbl0/internal/config"
 	"wbl0/internal/model"
 )
 
+// Ошибка при обращении к отсутствующему в БД заказу
+var ErrNotFound = errors.New("заказ не найден")
+
+// Ошибка при сохранении заказа, который уже есть в БД, с политикой DuplicateReject
+var ErrDuplicate = errors.New("заказ уже сохранен")
+
+// Политика сохранения заказа, order_uid которого уже есть в БД
+type DuplicatePolicy string
+
+const (
+	//Повторный заказ отклоняется с ошибкой ErrDuplicate
+	DuplicateReject DuplicatePolicy = "reject"
+	//Повторный заказ пропускается, в БД остается сохраненная версия
+	DuplicateIgnore DuplicatePolicy = "ignore"
+	//Сохраненный заказ заменяется, если повторный создан позже (по date_created), иначе повторный пропускается
+	DuplicateOverwriteIfNewer DuplicatePolicy = "overwrite-if-newer"
+)
+
+// Результат сохранения заказа
+type SaveResult int
+
+const (
+	//Заказ сохранен впервые
+	Inserted SaveResult = iota + 1
+	//Сохраненный ранее заказ заменен более новой версией
+	Updated
+	//Заказ уже был сохранен и не изменился
+	Skipped
+)
+
 // PostgresDB представляет БД PostgreSQL
 type PostgresDB struct {
-	db *sql.DB
+	db         *sql.DB
+	duplicates DuplicatePolicy
+	logger     *slog.Logger
+}
+
+// Дополнительная настройка БД
+type Option func(pg *PostgresDB)
+
+// Журнал БД. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(pg *PostgresDB) {
+		pg.logger = logger
+	}
+}
+
+// Политика сохранения повторных заказов. По умолчанию используется политика из config.Default()
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(pg *PostgresDB) {
+		pg.duplicates = policy
+	}
 }
 
 // Создает новый экземпляр БД и возвращает указатель на него
-func NewDB(db *sql.DB) *PostgresDB {
-	return &PostgresDB{db: db}
+func NewDB(db *sql.DB, opts ...Option) *PostgresDB {
+	pg := &PostgresDB{db: db, duplicates: DuplicatePolicy(config.Default().Postgres.DuplicatePolicy), logger: slog.Default()}
+	for _, opt := range opts {
+		opt(pg)
+	}
+	return pg
 }
 
-// Сохранение данных заказа в БД. Реализована в service.go
-func (pg *PostgresDB) SaveData(data model.OrderInfo) error {
-	return SaveToDB(pg.db, data)
+// Сохранение данных заказа, полученного из источника src, в БД с учетом политики сохранения повторных заказов
+func (pg *PostgresDB) SaveData(data model.OrderInfo, src model.Source) (SaveResult, error) {
+	return SaveToDB(pg.db, data, src, pg.duplicates)
 }
 
 // Инициализация и подключение к БД PostgreSQL. Возвращает указатель на созданное подключение.
-func InitDB(host string, port string, user string, password string, dbname string) *sql.DB {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
+func InitDB(cfg config.Postgres) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("ошибка при подключении к базе данных: %w", err)
 	}
 
-	return db
+	return db, nil
 }
 
 // Сохранение данных заказа в БД PostgreSQL с использованием транзакций.
-func SaveToDB(db *sql.DB, data model.OrderInfo) error {
+// Если заказ с таким order_uid уже сохранен, поведение определяется политикой policy.
+// В той же транзакции в журнал аудита записываются источник заказа src и результат сохранения,
+// в том числе для пропущенных и отклоненных повторных заказов.
+func SaveToDB(db *sql.DB, data model.OrderInfo, src model.Source, policy DuplicatePolicy) (result SaveResult, err error) {
+	defer observeSave(time.Now(), &err)
+
 	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = tx.Exec("insert into order_info (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
-		data.OrderUid, data.TrackNumber, data.Entry, data.Locale, data.InternalSignature, data.CustomerId, data.DeliveryService, data.Shardkey, data.SmId, data.DateCreated, data.OofShard)
-	if err != nil {
+	result, err = saveOrder(tx, data, policy)
+	action := result.auditAction()
+	if errors.Is(err, ErrDuplicate) {
+		//Отклоненный повторный заказ не изменяет БД, но его получение записывается в журнал аудита
+		action = model.AuditRejected
+	} else if err != nil {
 		tx.Rollback()
-		return err
+		return 0, err
+	}
+	if auditErr := writeAudit(tx, data.OrderUid, src, action); auditErr != nil {
+		tx.Rollback()
+		return 0, auditErr
 	}
 
-	_, err = tx.Exec("insert into deliveries (order_uid, name, phone, zip, city, address, region, email) values ($1, $2, $3, $4, $5, $6, $7, $8)",
+	if commitErr := tx.Commit(); commitErr != nil {
+		return 0, commitErr
+	}
+	return result, err
+}
+
+// Сохранение заказа в рамках транзакции tx.
+// Конфликт по order_uid разрешается в INSERT ... ON CONFLICT в соответствии с политикой policy,
+// доставка, оплата и товары перезаписываются только при сохранении новой версии заказа.
+func saveOrder(tx *sql.Tx, data model.OrderInfo, policy DuplicatePolicy) (SaveResult, error) {
+	onConflict := "on conflict (order_uid) do nothing"
+	if policy == DuplicateOverwriteIfNewer {
+		onConflict = `on conflict (order_uid) do update set
+			track_number = excluded.track_number, entry = excluded.entry, locale = excluded.locale,
+			internal_signature = excluded.internal_signature, customer_id = excluded.customer_id,
+			delivery_service = excluded.delivery_service, shardkey = excluded.shardkey, sm_id = excluded.sm_id,
+			date_created = excluded.date_created, oof_shard = excluded.oof_shard, updated_at = now()
+			where order_info.date_created < excluded.date_created`
+	}
+
+	//Если заказ не был вставлен или обновлен, returning не возвращает строк.
+	//xmax = 0 только у вставленной строки, у обновленной в xmax записан номер текущей транзакции
+	var inserted bool
+	err := tx.QueryRow("insert into order_info (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) "+onConflict+" returning (xmax = 0)",
+		data.OrderUid, data.TrackNumber, data.Entry, data.Locale, data.InternalSignature, data.CustomerId, data.DeliveryService, data.Shardkey, data.SmId, data.DateCreated, data.OofShard).Scan(&inserted)
+	if errors.Is(err, sql.ErrNoRows) {
+		if policy == DuplicateReject {
+			return 0, fmt.Errorf("%w: %s", ErrDuplicate, data.OrderUid)
+		}
+		return Skipped, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`insert into deliveries (order_uid, name, phone, zip, city, address, region, email) values ($1, $2, $3, $4, $5, $6, $7, $8)
+		on conflict (order_uid) do update set name = excluded.name, phone = excluded.phone, zip = excluded.zip, city = excluded.city,
+		address = excluded.address, region = excluded.region, email = excluded.email`,
 		data.OrderUid, data.Delivery.Name, data.Delivery.Phone, data.Delivery.Zip, data.Delivery.City, data.Delivery.Address, data.Delivery.Region, data.Delivery.Email)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return 0, err
 	}
 
-	_, err = tx.Exec("insert into payments (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+	_, err = tx.Exec(`insert into payments (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		on conflict (order_uid) do update set transaction = excluded.transaction, request_id = excluded.request_id, currency = excluded.currency,
+		provider = excluded.provider, amount = excluded.amount, payment_dt = excluded.payment_dt, bank = excluded.bank,
+		delivery_cost = excluded.delivery_cost, goods_total = excluded.goods_total, custom_fee = excluded.custom_fee`,
 		data.OrderUid, data.Payment.Transaction, data.Payment.RequestId, data.Payment.Currency, data.Payment.Provider, data.Payment.Amount, data.Payment.PaymentDt, data.Payment.Bank, data.Payment.DeliveryCost, data.Payment.GoodsTotal, data.Payment.CustomFee)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return 0, err
+	}
+
+	result := Inserted
+	if !inserted {
+		//Состав товаров новой версии заказа может отличаться, поэтому товары, которых в ней нет, удаляются
+		result = Updated
+		chrtIds := make([]int64, len(data.Items))
+		for i, item := range data.Items {
+			chrtIds[i] = item.ChrtId
+		}
+		if _, err = tx.Exec("delete from items where order_uid = $1 and not (chrt_id = any($2))", data.OrderUid, pq.Array(chrtIds)); err != nil {
+			return 0, err
+		}
 	}
 
+	//Статус товара, который уже был сохранен, изменяется только событиями изменения статуса
+	//и записывается в order_status_history, поэтому новая версия заказа его не перезаписывает
 	for _, item := range data.Items {
-		_, err = tx.Exec("insert into items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		_, err = tx.Exec(`insert into items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			on conflict (order_uid, chrt_id) do update set track_number = excluded.track_number, price = excluded.price, rid = excluded.rid,
+			name = excluded.name, sale = excluded.sale, size = excluded.size, total_price = excluded.total_price, nm_id = excluded.nm_id, brand = excluded.brand`,
 			data.OrderUid, item.ChrtId, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmId, item.Brand, item.Status)
 		if err != nil {
-			tx.Rollback()
-			return err
+			return 0, err
 		}
 	}
 
-	return tx.Commit()
+	return result, nil
 }
 
-// Получение данных заказа по идентификатору из БД PostgreSQL
-func (pg *PostgresDB) GetDataById(id string) (model.OrderInfo, error) {
-	row := pg.db.QueryRow(`select 
-    track_number, 
-    entry, 
-    locale, 
-    internal_signature, 
-    customer_id, 
-    delivery_service, 
-    shardkey, 
-    sm_id, 
-    date_created, 
-    oof_shard 
-from order_info 
-where order_uid=$1`,
-		id)
+// Запрос заказов вместе с доставкой, оплатой и товарами за одно обращение к БД.
+// Доставка и оплата присоединяются к заказу, товары агрегируются в JSON-массив с ключами, совпадающими с JSON-тегами model.Item.
+// Условие отбора и сортировка добавляются в конец запроса.
+const selectOrders = `select
+	o.order_uid,
+	o.track_number,
+	o.entry,
+	o.locale,
+	o.internal_signature,
+	o.customer_id,
+	o.delivery_service,
+	o.shardkey,
+	o.sm_id,
+	o.date_created,
+	o.oof_shard,
+	d.name,
+	d.phone,
+	d.zip,
+	d.city,
+	d.address,
+	d.region,
+	d.email,
+	p.transaction,
+	p.request_id,
+	p.currency,
+	p.provider,
+	p.amount,
+	p.payment_dt,
+	p.bank,
+	p.delivery_cost,
+	p.goods_total,
+	p.custom_fee,
+	i.items
+from order_info o
+join deliveries d on d.order_uid = o.order_uid
+join payments p on p.order_uid = o.order_uid
+left join lateral (
+	select json_agg(json_build_object(
+		'chrt_id', it.chrt_id,
+		'track_number', it.track_number,
+		'price', it.price,
+		'rid', it.rid,
+		'name', it.name,
+		'sale', it.sale,
+		'size', it.size,
+		'total_price', it.total_price,
+		'nm_id', it.nm_id,
+		'brand', it.brand,
+		'status', it.status
+	) order by it.chrt_id) as items
+	from items it
+	where it.order_uid = o.order_uid
+) i on true
+`
+
+// Источник строк результата запроса (*sql.Row или *sql.Rows)
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Чтение заказа из строки результата запроса selectOrders
+func scanOrder(row scanner) (model.OrderInfo, error) {
 	var order model.OrderInfo
-	var trackNumber, entry, locale, internalSignature, customerId, deliveryService, shardkey, dateCreated, oofShard string
-	var smId int
-	err := row.Scan(&trackNumber, &entry, &locale, &internalSignature, &customerId, &deliveryService, &shardkey, &smId, &dateCreated, &oofShard)
+	var items []byte
+	err := row.Scan(
+		&order.OrderUid,
+		&order.TrackNumber,
+		&order.Entry,
+		&order.Locale,
+		&order.InternalSignature,
+		&order.CustomerId,
+		&order.DeliveryService,
+		&order.Shardkey,
+		&order.SmId,
+		&order.DateCreated,
+		&order.OofShard,
+		&order.Delivery.Name,
+		&order.Delivery.Phone,
+		&order.Delivery.Zip,
+		&order.Delivery.City,
+		&order.Delivery.Address,
+		&order.Delivery.Region,
+		&order.Delivery.Email,
+		&order.Payment.Transaction,
+		&order.Payment.RequestId,
+		&order.Payment.Currency,
+		&order.Payment.Provider,
+		&order.Payment.Amount,
+		&order.Payment.PaymentDt,
+		&order.Payment.Bank,
+		&order.Payment.DeliveryCost,
+		&order.Payment.GoodsTotal,
+		&order.Payment.CustomFee,
+		&items,
+	)
 	if err != nil {
 		return model.OrderInfo{}, err
 	}
+	//Драйвер возвращает время в часовом поясе сессии, в модели оно хранится в UTC
+	order.DateCreated = order.DateCreated.UTC()
 
-	order.OrderUid = id
-	order.TrackNumber = trackNumber
-	order.Entry = entry
-	order.Locale = locale
-	order.InternalSignature = internalSignature
-	order.CustomerId = customerId
-	order.DeliveryService = deliveryService
-	order.Shardkey = shardkey
-	order.SmId = smId
-	order.DateCreated = dateCreated
-	order.OofShard = oofShard
-
-	row = pg.db.QueryRow(`
-select name, phone, zip, city, address, region, email 
-from deliveries 
-where order_uid=$1`, id)
-	var delivery model.Delivery
-	err = row.Scan(&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email)
-	if err != nil {
-		return model.OrderInfo{}, err
+	//У заказа без товаров json_agg возвращает NULL
+	if items != nil {
+		if err := json.Unmarshal(items, &order.Items); err != nil {
+			return model.OrderInfo{}, fmt.Errorf("ошибка при разборе товаров заказа %s: %w", order.OrderUid, err)
+		}
 	}
 
-	order.Delivery = delivery
-
-	row = pg.db.QueryRow(`select 
-    transaction, 
-    request_id, 
-    currency, 
-    provider, 
-    amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-from payments where order_uid=$1`,
-		id)
-	var payment model.Payment
-	err = row.Scan(&payment.Transaction, &payment.RequestId, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDt, &payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee)
-	if err != nil {
-		return model.OrderInfo{}, err
+	return order, nil
+}
+
+// Получение данных заказа по идентификатору из БД PostgreSQL.
+// Если заказа нет, возвращается ошибка ErrNotFound.
+func (pg *PostgresDB) GetDataById(id string) (model.OrderInfo, error) {
+	order, err := scanOrder(pg.db.QueryRow(selectOrders+"where o.order_uid = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.OrderInfo{}, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
+	return order, err
+}
 
-	order.Payment = payment
-
-	rows, err := pg.db.Query(`SELECT 
-    chrt_id, 
-    track_number, 
-    price, 
-    rid, 
-    name, 
-    sale, 
-    size, 
-    total_price, 
-    nm_id, 
-    brand, 
-    status 
-from items where order_uid=$1`,
-		id)
-	if err != nil {
-		return model.OrderInfo{}, err
+// Получение всех данных заказов из PostgreSQL
+func (pg *PostgresDB) GetAllData() ([]model.OrderInfo, error) {
+	var data []model.OrderInfo
+	err := pg.ForEachOrder(func(order model.OrderInfo) error {
+		data = append(data, order)
+		return nil
+	})
+	return data, err
+}
+
+// Получение страницы заказов, отсортированных по order_uid, начиная после заказа after.
+// Пустой after означает первую страницу. Используется keyset-пагинация по первичному ключу.
+func (pg *PostgresDB) ListOrders(after string, limit int) ([]model.OrderInfo, error) {
+	return pg.SearchOrders(model.OrderFilter{}, after, limit)
+}
+
+// Поиск заказов по условиям filter. Результат отсортирован по order_uid и разбит на страницы так же, как в ListOrders
+func (pg *PostgresDB) SearchOrders(filter model.OrderFilter, after string, limit int) ([]model.OrderInfo, error) {
+	args := []any{after}
+	conds := []string{"o.order_uid > $1"}
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
 	}
-	defer rows.Close()
 
-	var items []model.Item
-	for rows.Next() {
-		var item model.Item
-		err := rows.Scan(&item.ChrtId, &item.TrackNumber, &item.Price, &item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmId, &item.Brand, &item.Status)
-		if err != nil {
-			return model.OrderInfo{}, err
-		}
-		items = append(items, item)
+	if filter.CustomerId != "" {
+		addCond("o.customer_id = $%d", filter.CustomerId)
+	}
+	if filter.TrackNumber != "" {
+		addCond("o.track_number = $%d", filter.TrackNumber)
+	}
+	if filter.DeliveryService != "" {
+		addCond("o.delivery_service = $%d", filter.DeliveryService)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		addCond("o.date_created >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		addCond("o.date_created < $%d", filter.CreatedTo)
+	}
+	if filter.Rid != "" {
+		addCond("exists (select 1 from items f where f.order_uid = o.order_uid and f.rid = $%d)", filter.Rid)
+	}
+	if filter.NmId != 0 {
+		addCond("exists (select 1 from items f where f.order_uid = o.order_uid and f.nm_id = $%d)", filter.NmId)
 	}
 
-	order.Items = items
+	args = append(args, limit)
+	where := fmt.Sprintf("where %s order by o.order_uid limit $%d", strings.Join(conds, " and "), len(args))
 
-	return order, nil
+	var data []model.OrderInfo
+	err := pg.forEachOrder(func(order model.OrderInfo) error {
+		data = append(data, order)
+		return nil
+	}, where, args...)
+	return data, err
 }
 
-// Получение всех данных заказов из PostgreSQL
-func (pg *PostgresDB) GetAllData() ([]model.OrderInfo, error) {
-	rows, err := pg.db.Query(`select 
-    order_uid, 
-    track_number, 
-    entry, 
-    locale, 
-    internal_signature, 
-    customer_id, 
-    delivery_service, 
-    shardkey, 
-    sm_id, 
-    date_created, 
-    oof_shard 
-	from order_info`)
+// Потоковое чтение всех заказов из PostgreSQL.
+// Каждый заказ передается в fn сразу после чтения, поэтому в памяти не накапливается весь результат.
+// Если fn возвращает ошибку, чтение прекращается и ошибка возвращается вызывающему.
+func (pg *PostgresDB) ForEachOrder(fn func(order model.OrderInfo) error) error {
+	return pg.forEachOrder(fn, "")
+}
+
+// Потоковое чтение заказов, измененных не раньше watermark, из PostgreSQL.
+// Время изменения берется по часам БД, а не из date_created, поэтому учитываются заказы, полученные с опозданием,
+// замененные более новой версией и заказы с измененным статусом товаров.
+func (pg *PostgresDB) ForEachOrderUpdatedAfter(watermark time.Time, fn func(order model.OrderInfo) error) error {
+	return pg.forEachOrder(fn, "where o.updated_at >= $1", watermark)
+}
+
+// Запас водяного знака на транзакции, которые начались до его получения, а зафиксированы после
+const watermarkLag = time.Minute
+
+// Водяной знак для сверки снимка кэша с БД: текущее время по часам БД за вычетом watermarkLag.
+// Все изменения заказов, зафиксированные после получения водяного знака, читаются ForEachOrderUpdatedAfter.
+func (pg *PostgresDB) Watermark() (time.Time, error) {
+	var now time.Time
+	if err := pg.db.QueryRow("select now()").Scan(&now); err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-watermarkLag).UTC(), nil
+}
+
+// Потоковое чтение заказов, удовлетворяющих условию where, из PostgreSQL
+func (pg *PostgresDB) forEachOrder(fn func(order model.OrderInfo) error, where string, args ...any) error {
+	rows, err := pg.db.Query(selectOrders+where, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var data []model.OrderInfo
 	for rows.Next() {
-		var order model.OrderInfo
-		if err := rows.Scan(
-			&order.OrderUid,
-			&order.TrackNumber,
-			&order.Entry,
-			&order.Locale,
-			&order.InternalSignature,
-			&order.CustomerId,
-			&order.DeliveryService,
-			&order.Shardkey,
-			&order.SmId,
-			&order.DateCreated,
-			&order.OofShard,
-		); err != nil {
-			log.Printf("Ошибка при сканировании данных из базы данных: %v\n", err)
-			return nil, err
-		}
-
-		deliveryRow := pg.db.QueryRow(`SELECT name, phone, zip, city, address, region, email FROM deliveries WHERE order_uid = $1`,
-			order.OrderUid)
-		var delivery model.Delivery
-		if err := deliveryRow.Scan(
-			&delivery.Name,
-			&delivery.Phone,
-			&delivery.Zip,
-			&delivery.City,
-			&delivery.Address,
-			&delivery.Region,
-			&delivery.Email,
-		); err != nil {
-			log.Printf("Ошибка при сканировании данных из таблицы delivery: %v\n", err)
-			return nil, err
-		}
-		order.Delivery = delivery
-
-		paymentRow := pg.db.QueryRow(`select transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee 
-			from payments 
-			where order_uid = $1`,
-			order.OrderUid)
-		var payment model.Payment
-		if err := paymentRow.Scan(
-			&payment.Transaction,
-			&payment.RequestId,
-			&payment.Currency,
-			&payment.Provider,
-			&payment.Amount,
-			&payment.PaymentDt,
-			&payment.Bank,
-			&payment.DeliveryCost,
-			&payment.GoodsTotal,
-			&payment.CustomFee,
-		); err != nil {
-			log.Printf("Ошибка при сканировании данных из таблицы payment: %v\n", err)
-			return nil, err
-		}
-		order.Payment = payment
-
-		itemRows, err := pg.db.Query(`select
-    chrt_id, 
-    track_number, 
-    price, 
-    rid, 
-    name, 
-    sale, 
-    size, 
-    total_price, 
-    nm_id, 
-    brand, 
-    status 
-from items where order_uid = $1`, order.OrderUid)
+		order, err := scanOrder(rows)
 		if err != nil {
-			log.Printf("Ошибка при выполнении запроса к таблице item: %v\n", err)
-			return nil, err
+			pg.logger.Error("Ошибка при сканировании данных из базы данных", "error", err)
+			return err
 		}
-		defer itemRows.Close()
-
-		var items []model.Item
-		for itemRows.Next() {
-			var item model.Item
-			if err := itemRows.Scan(
-				&item.ChrtId,
-				&item.TrackNumber,
-				&item.Price,
-				&item.Rid,
-				&item.Name,
-				&item.Sale,
-				&item.Size,
-				&item.TotalPrice,
-				&item.NmId,
-				&item.Brand,
-				&item.Status,
-			); err != nil {
-				log.Printf("Ошибка при сканировании данных из таблицы item: %v\n", err)
-				return nil, err
-			}
-			items = append(items, item)
+		if err := fn(order); err != nil {
+			return err
 		}
-		order.Items = items
-
-		data = append(data, order)
 	}
 
-	return data, nil
+	return rows.Err()
 }
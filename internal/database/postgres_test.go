@@ -1,8 +1,13 @@
 package database
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
+	"wbl0/internal/config"
+	"wbl0/internal/model"
 	"wbl0/testutils"
 )
 
@@ -15,11 +20,133 @@ func TestPostgresDB_GetDataById(t *testing.T) {
 	pgDB := NewDB(db)
 
 	//Сохранение заказа в БД
-	err := pgDB.SaveData(testutils.TestOrder)
+	result, err := pgDB.SaveData(testutils.TestOrder, model.Source{})
 	assert.NoError(t, err)
+	assert.Equal(t, Inserted, result)
 
 	//Получение заказа по его ИД
 	data, err := pgDB.GetDataById(testutils.TestOrder.OrderUid)
 	assert.NoError(t, err)
 	assert.Equal(t, testutils.TestOrder, data)
 }
+
+func TestPostgresDB_ForEachOrder(t *testing.T) {
+	//Подготовка к тестированию БД
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	pgDB := NewDB(db)
+
+	//Заказ с несколькими товарами
+	second := testutils.TestOrder
+	second.OrderUid = "second"
+	second.DateCreated = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	second.Items = []model.Item{testutils.TestOrder.Items[0], testutils.TestOrder.Items[0]}
+	second.Items[1].ChrtId++
+
+	_, err := pgDB.SaveData(testutils.TestOrder, model.Source{})
+	assert.NoError(t, err)
+	_, err = pgDB.SaveData(second, model.Source{})
+	assert.NoError(t, err)
+
+	//Все заказы читаются одним запросом вместе с товарами
+	orders := map[string]model.OrderInfo{}
+	err = pgDB.ForEachOrder(func(order model.OrderInfo) error {
+		orders[order.OrderUid] = order
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, testutils.TestOrder, orders[testutils.TestOrder.OrderUid])
+	assert.Equal(t, second, orders[second.OrderUid])
+
+	//Отбор заказов по времени изменения в БД, а не по дате создания
+	watermark, err := pgDB.Watermark()
+	assert.NoError(t, err)
+	var uids []string
+	err = pgDB.ForEachOrderUpdatedAfter(watermark, func(order model.OrderInfo) error {
+		uids = append(uids, order.OrderUid)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{testutils.TestOrder.OrderUid, second.OrderUid}, uids)
+
+	uids = nil
+	err = pgDB.ForEachOrderUpdatedAfter(watermark.Add(time.Hour), func(order model.OrderInfo) error {
+		uids = append(uids, order.OrderUid)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, uids)
+
+	//Ошибка из fn прекращает чтение
+	stop := errors.New("stop")
+	calls := 0
+	err = pgDB.ForEachOrder(func(order model.OrderInfo) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestPostgresDB_SaveDataDuplicates(t *testing.T) {
+	//Подготовка к тестированию БД
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+
+	//Более новая версия заказа с другим составом товаров
+	newer := testutils.TestOrder
+	newer.DateCreated = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer.Delivery.City = "Tel Aviv"
+	newer.Items = []model.Item{testutils.TestOrder.Items[0], testutils.TestOrder.Items[0]}
+	newer.Items[1].ChrtId++
+
+	_, err := NewDB(db).SaveData(testutils.TestOrder, model.Source{})
+	assert.NoError(t, err)
+
+	//reject: повторный заказ отклоняется типизированной ошибкой, а не ошибкой первичного ключа
+	_, err = NewDB(db, WithDuplicatePolicy(DuplicateReject)).SaveData(newer, model.Source{})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	//Отклоненный повторный заказ записывается в журнал аудита
+	history, err := NewDB(db).OrderHistory(newer.OrderUid)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, model.AuditRejected, history[1].Action)
+
+	//ignore: повторный заказ пропускается
+	result, err := NewDB(db, WithDuplicatePolicy(DuplicateIgnore)).SaveData(newer, model.Source{})
+	assert.NoError(t, err)
+	assert.Equal(t, Skipped, result)
+
+	//overwrite-if-newer: более старая версия пропускается, более новая заменяет сохраненную
+	pgDB := NewDB(db, WithDuplicatePolicy(DuplicateOverwriteIfNewer))
+	result, err = pgDB.SaveData(testutils.TestOrder, model.Source{})
+	assert.NoError(t, err)
+	assert.Equal(t, Skipped, result)
+
+	result, err = pgDB.SaveData(newer, model.Source{})
+	assert.NoError(t, err)
+	assert.Equal(t, Updated, result)
+
+	data, err := pgDB.GetDataById(newer.OrderUid)
+	assert.NoError(t, err)
+	assert.Equal(t, newer, data)
+
+	//Повторная замена сохраняет статус товара, который уже был в заказе
+	require.NoError(t, pgDB.UpdateStatus(model.StatusUpdate{OrderUid: newer.OrderUid, ChrtId: newer.Items[0].ChrtId, Status: model.StatusAssembled}))
+	newest := newer
+	newest.DateCreated = newer.DateCreated.Add(time.Hour)
+	result, err = pgDB.SaveData(newest, model.Source{})
+	assert.NoError(t, err)
+	assert.Equal(t, Updated, result)
+
+	data, err = pgDB.GetDataById(newer.OrderUid)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusAssembled, data.Items[0].Status)
+	assert.Equal(t, newer.Items[1].Status, data.Items[1].Status)
+}
+
+func TestNewDB_DefaultDuplicatePolicy(t *testing.T) {
+	//Политика по умолчанию совпадает с политикой сервиса по умолчанию
+	assert.Equal(t, DuplicatePolicy(config.Default().Postgres.DuplicatePolicy), NewDB(nil).duplicates)
+}
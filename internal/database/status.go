@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"time"
+	"wbl0/internal/model"
+)
+
+// Изменение статуса товаров заказа в соответствии с событием update.
+// Текущие статусы товаров блокируются до конца транзакции, поэтому параллельные события одного заказа
+// применяются последовательно. Каждый переход проверяется по model.ValidateTransition и записывается в order_status_history.
+// Если заказ или товар не найден, возвращается ErrNotFound.
+func (pg *PostgresDB) UpdateStatus(update model.StatusUpdate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "select chrt_id, status from items where order_uid = $1"
+	args := []any{update.OrderUid}
+	if update.ChrtId != 0 {
+		query += " and chrt_id = $2"
+		args = append(args, update.ChrtId)
+	}
+	rows, err := tx.Query(query+" order by chrt_id for update", args...)
+	if err != nil {
+		return err
+	}
+
+	type itemStatus struct {
+		chrtId int64
+		status int
+	}
+	var items []itemStatus
+	for rows.Next() {
+		var item itemStatus
+		if err := rows.Scan(&item.chrtId, &item.status); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return ErrNotFound
+	}
+
+	changedAt := update.UpdatedAt
+	if changedAt.IsZero() {
+		changedAt = time.Now()
+	}
+
+	for _, item := range items {
+		if err := model.ValidateTransition(item.status, update.Status); err != nil {
+			return fmt.Errorf("товар %d заказа %s: %w", item.chrtId, update.OrderUid, err)
+		}
+
+		_, err = tx.Exec("update items set status = $1 where order_uid = $2 and chrt_id = $3", update.Status, update.OrderUid, item.chrtId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("insert into order_status_history (order_uid, chrt_id, old_status, new_status, changed_at) values ($1, $2, $3, $4, $5)",
+			update.OrderUid, item.chrtId, item.status, update.Status, changedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	//Изменение статуса - изменение заказа для сверки снимка кэша с БД
+	if _, err := tx.Exec("update order_info set updated_at = now() where order_uid = $1", update.OrderUid); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
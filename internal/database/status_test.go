@@ -0,0 +1,41 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/internal/model"
+	"wbl0/testutils"
+)
+
+func TestPostgresDB_UpdateStatus(t *testing.T) {
+	//Подготовка к тестированию БД
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	pgDB := NewDB(db)
+
+	_, err := pgDB.SaveData(testutils.TestOrder, model.Source{})
+	require.NoError(t, err)
+	uid := testutils.TestOrder.OrderUid
+
+	//Допустимый переход меняет статус всех товаров и записывается в историю
+	require.NoError(t, pgDB.UpdateStatus(model.StatusUpdate{OrderUid: uid, Status: model.StatusAssembled}))
+
+	data, err := pgDB.GetDataById(uid)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusAssembled, data.Items[0].Status)
+
+	var oldStatus, newStatus int
+	err = db.QueryRow("select old_status, new_status from order_status_history where order_uid = $1", uid).Scan(&oldStatus, &newStatus)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusAccepted, oldStatus)
+	assert.Equal(t, model.StatusAssembled, newStatus)
+
+	//Недопустимый переход не меняет статус
+	err = pgDB.UpdateStatus(model.StatusUpdate{OrderUid: uid, Status: model.StatusReturned})
+	assert.ErrorIs(t, err, model.ErrInvalidTransition)
+
+	//Неизвестный товар
+	err = pgDB.UpdateStatus(model.StatusUpdate{OrderUid: uid, ChrtId: -1, Status: model.StatusShipped})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
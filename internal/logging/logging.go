@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"wbl0/internal/config"
+)
+
+// Ключ атрибута с идентификатором корреляции запроса или сообщения
+const CorrelationIDKey = "correlation_id"
+
+// Ключ контекста для идентификатора корреляции
+type correlationIDKey struct{}
+
+// Создание журнала с уровнем и форматом из cfg, который пишет в w.
+// Если в контексте записи есть идентификатор корреляции, он добавляется к записи атрибутом correlation_id.
+func New(cfg config.Log, w io.Writer) *slog.Logger {
+	var level slog.Level
+	//Значение уровня проверено в config.Validate
+	level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Новый случайный идентификатор корреляции
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Контекст с идентификатором корреляции id
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// Идентификатор корреляции из контекста или пустая строка
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Обработчик записей, добавляющий идентификатор корреляции из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String(CorrelationIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,27 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/internal/config"
+)
+
+func TestNew_CorrelationID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.Log{Level: "info", Format: "json"}, &buf)
+
+	ctx := WithCorrelationID(context.Background(), "abc")
+	logger.InfoContext(ctx, "сообщение", "key", "value")
+	logger.DebugContext(ctx, "не записывается")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "сообщение", record["msg"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "abc", record[CorrelationIDKey])
+	assert.Equal(t, "value", record["key"])
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Действия, которые записываются в журнал аудита при получении заказа
+const (
+	AuditInserted = "inserted"
+	AuditUpdated  = "updated"
+	AuditSkipped  = "skipped"
+	AuditRejected = "rejected"
+)
+
+// Источник сохраняемого заказа: сообщение NATS, из которого он получен
+type Source struct {
+	//Канал NATS
+	Subject string
+	//Номер сообщения в потоке JetStream, 0 для сообщений без JetStream
+	Sequence uint64
+	//Время получения сообщения
+	ReceivedAt time.Time
+	//SHA-256 содержимого сообщения в шестнадцатеричном виде
+	PayloadHash string
+}
+
+// Запись журнала аудита заказа
+type AuditEntry struct {
+	OrderUid    string    `json:"order_uid"`
+	Subject     string    `json:"subject"`
+	Sequence    uint64    `json:"sequence"`
+	ReceivedAt  time.Time `json:"received_at"`
+	PayloadHash string    `json:"payload_hash"`
+	Action      string    `json:"action"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "log/slog"
+
+// Значение, которое записывается в журнал вместо персональных данных
+const redacted = "[REDACTED]"
+
+// Представление доставки в журнале.
+// Персональные данные покупателя (имя, телефон, индекс, адрес и email) заменяются на [REDACTED].
+func (d Delivery) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", redact(d.Name)),
+		slog.String("phone", redact(d.Phone)),
+		slog.String("zip", redact(d.Zip)),
+		slog.String("city", d.City),
+		slog.String("address", redact(d.Address)),
+		slog.String("region", d.Region),
+		slog.String("email", redact(d.Email)),
+	)
+}
+
+// Краткое представление заказа в журнале. Доставка записывается без персональных данных
+func (oi OrderInfo) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", oi.OrderUid),
+		slog.String("track_number", oi.TrackNumber),
+		slog.String("customer_id", oi.CustomerId),
+		slog.String("delivery_service", oi.DeliveryService),
+		slog.Time("date_created", oi.DateCreated),
+		slog.Any("delivery", oi.Delivery),
+		slog.Int("items", len(oi.Items)),
+	)
+}
+
+// Замена непустого значения на [REDACTED]
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
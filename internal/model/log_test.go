@@ -0,0 +1,33 @@
+package model
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestOrderInfo_LogValueRedactsDelivery(t *testing.T) {
+	order := OrderInfo{
+		OrderUid: "b563feb7b2b84b6test",
+		Delivery: Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("заказ", "order", order)
+
+	out := buf.String()
+	assert.Contains(t, out, order.OrderUid)
+	assert.Contains(t, out, order.Delivery.City)
+	for _, pii := range []string{"Test Testov", "+9720000000", "2639809", "Ploshad Mira 15", "test@gmail.com"} {
+		assert.NotContains(t, out, pii)
+	}
+}
@@ -1,65 +1,96 @@
 package model
 
-import "github.com/go-playground/validator/v10"
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"time"
+)
 
 type OrderInfo struct {
-	OrderUid          string   `json:"order_uid"`
-	TrackNumber       string   `json:"track_number"`
-	Entry             string   `json:"entry"`
-	Delivery          Delivery `json:"delivery"`
-	Payment           Payment  `json:"payment"`
-	Items             []Item   `json:"items"`
-	Locale            string   `json:"locale"`
-	InternalSignature string   `json:"internal_signature"`
-	CustomerId        string   `json:"customer_id"`
-	DeliveryService   string   `json:"delivery_service"`
-	Shardkey          string   `json:"shardkey"`
-	SmId              int      `json:"sm_id"`
-	DateCreated       string   `json:"date_created"`
-	OofShard          string   `json:"oof_shard"`
+	OrderUid          string    `json:"order_uid" validate:"required"`
+	TrackNumber       string    `json:"track_number" validate:"required"`
+	Entry             string    `json:"entry" validate:"required"`
+	Delivery          Delivery  `json:"delivery"`
+	Payment           Payment   `json:"payment"`
+	Items             []Item    `json:"items" validate:"required,min=1,dive"`
+	Locale            string    `json:"locale" validate:"required,bcp47_language_tag"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerId        string    `json:"customer_id" validate:"required"`
+	DeliveryService   string    `json:"delivery_service" validate:"required"`
+	Shardkey          string    `json:"shardkey" validate:"required"`
+	SmId              int       `json:"sm_id" validate:"min=0"`
+	DateCreated       time.Time `json:"date_created" validate:"required"`
+	OofShard          string    `json:"oof_shard" validate:"required"`
 }
 
 type Delivery struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
-	Zip     string `json:"zip"`
-	City    string `json:"city"`
-	Address string `json:"address"`
-	Region  string `json:"region"`
-	Email   string `json:"email"`
+	Name    string `json:"name" validate:"required"`
+	Phone   string `json:"phone" validate:"required,e164"`
+	Zip     string `json:"zip" validate:"required"`
+	City    string `json:"city" validate:"required"`
+	Address string `json:"address" validate:"required"`
+	Region  string `json:"region" validate:"required"`
+	Email   string `json:"email" validate:"required,email"`
 }
 
 type Payment struct {
-	Transaction  string `json:"transaction"`
+	Transaction  string `json:"transaction" validate:"required"`
 	RequestId    string `json:"request_id"`
-	Currency     string `json:"currency"`
-	Provider     string `json:"provider"`
-	Amount       int    `json:"amount"`
-	PaymentDt    int64  `json:"payment_dt"`
-	Bank         string `json:"bank"`
-	DeliveryCost int    `json:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total"`
-	CustomFee    int    `json:"custom_fee"`
+	Currency     string `json:"currency" validate:"required,iso4217"`
+	Provider     string `json:"provider" validate:"required"`
+	Amount       int64  `json:"amount" validate:"min=0"`
+	PaymentDt    int64  `json:"payment_dt" validate:"required"`
+	Bank         string `json:"bank" validate:"required"`
+	DeliveryCost int64  `json:"delivery_cost" validate:"min=0"`
+	GoodsTotal   int64  `json:"goods_total" validate:"min=0"`
+	CustomFee    int64  `json:"custom_fee" validate:"min=0"`
 }
 
 type Item struct {
-	ChrtId      int64  `json:"chrt_id"`
-	TrackNumber string `json:"track_number"`
-	Price       int    `json:"price"`
-	Rid         string `json:"rid"`
-	Name        string `json:"name"`
-	Sale        int    `json:"sale"`
-	Size        string `json:"size"`
-	TotalPrice  int    `json:"total_price"`
-	NmId        int64  `json:"nm_id"`
-	Brand       string `json:"brand"`
-	Status      int    `json:"status"`
+	ChrtId      int64  `json:"chrt_id" validate:"required"`
+	TrackNumber string `json:"track_number" validate:"required"`
+	Price       int64  `json:"price" validate:"min=0"`
+	Rid         string `json:"rid" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Sale        int    `json:"sale" validate:"min=0,max=100"`
+	Size        string `json:"size" validate:"required"`
+	TotalPrice  int64  `json:"total_price" validate:"min=0"`
+	NmId        int64  `json:"nm_id" validate:"required"`
+	Brand       string `json:"brand" validate:"required"`
+	Status      int    `json:"status" validate:"required"`
 }
 
 // Экземпляр валидатора для проверки полей структур на соответствие тегам validate
 var validate = validator.New()
 
-// Валидация структуры OrderInfo
+// Валидация структуры OrderInfo: политики строковых полей, правила из тегов validate и бизнес-правила заказа.
+// Перед проверкой строковые поля нормализуются по политикам, поэтому oi может измениться.
+// Возвращает ValidationErrors со всеми нарушениями или nil.
 func (oi *OrderInfo) Validate() error {
-	return validate.Struct(oi)
+	errs := applyFieldPolicies(reflect.ValueOf(oi).Elem(), "", "")
+	errs, err := appendFieldErrors(errs, validate.Struct(oi))
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Условия поиска заказов. Пустые поля не участвуют в отборе
+type OrderFilter struct {
+	CustomerId      string
+	TrackNumber     string
+	DeliveryService string
+	Rid             string
+	NmId            int64
+	//Диапазон даты создания заказа: CreatedFrom включительно, CreatedTo не включительно
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// Проверка того, что задано хотя бы одно условие поиска
+func (f OrderFilter) IsEmpty() bool {
+	return f == OrderFilter{}
 }
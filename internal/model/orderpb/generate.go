@@ -0,0 +1,5 @@
+// Сообщения с заказом в формате protobuf, описанные в order.proto.
+// После изменения order.proto код пересоздается командой go generate ./internal/model/orderpb
+package orderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative order.proto
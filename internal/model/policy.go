@@ -0,0 +1,201 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Наборы допустимых символов строковых полей
+const (
+	//Печатные символы Unicode и пробел
+	CharsetPrintable = "printable"
+	//Печатные символы ASCII и пробел
+	CharsetASCII = "ascii"
+	//Буквы, цифры, дефис, подчеркивание и точка: идентификаторы, в том числе UUID
+	CharsetIdentifier = "identifier"
+	//Буквы и цифры
+	CharsetAlnum = "alnum"
+	//Цифры 0-9
+	CharsetDigits = "digits"
+)
+
+// Нормализации строковых полей. Применяются перед проверкой в порядке перечисления в политике
+const (
+	//Удаление пробелов в начале и конце строки
+	NormalizeTrim = "trim"
+	//Замена последовательностей пробельных символов одним пробелом
+	NormalizeCollapseSpaces = "collapse_spaces"
+	NormalizeLower          = "lower"
+	NormalizeUpper          = "upper"
+)
+
+// Проверки символов для каждого набора
+var charsets = map[string]func(r rune) bool{
+	CharsetPrintable:  unicode.IsPrint,
+	CharsetASCII:      func(r rune) bool { return r >= ' ' && r <= '~' },
+	CharsetIdentifier: func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.", r) },
+	CharsetAlnum:      func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	CharsetDigits:     func(r rune) bool { return r >= '0' && r <= '9' },
+}
+
+// Функции нормализации
+var normalizers = map[string]func(s string) string{
+	NormalizeTrim:           strings.TrimSpace,
+	NormalizeCollapseSpaces: func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	NormalizeLower:          strings.ToLower,
+	NormalizeUpper:          strings.ToUpper,
+}
+
+// Политика строкового поля заказа: допустимые символы, максимальная длина в символах и нормализация.
+// Пустой Charset и нулевой MaxLength означают отсутствие ограничения
+type FieldPolicy struct {
+	Charset   string
+	MaxLength int
+	Normalize []string
+}
+
+// Нормализация для идентификаторов и кодов
+var trim = []string{NormalizeTrim}
+
+// Нормализация для произвольного текста: имен, адресов, названий
+var trimAndCollapse = []string{NormalizeTrim, NormalizeCollapseSpaces}
+
+// Политики полей по умолчанию. Ключ - путь к полю из JSON-имен без индексов массивов, например items.rid.
+// MaxLength совпадает с размером VARCHAR соответствующего столбца в БД и не может быть увеличен.
+// Для идентификаторов по умолчанию допускаются дефис, подчеркивание и точка, более строгий alnum задается в конфигурации
+var defaultFieldPolicies = map[string]FieldPolicy{
+	"order_uid":          {CharsetIdentifier, 50, trim},
+	"track_number":       {CharsetIdentifier, 50, trim},
+	"entry":              {CharsetIdentifier, 50, trim},
+	"locale":             {CharsetASCII, 10, trim},
+	"internal_signature": {CharsetPrintable, 100, trim},
+	"customer_id":        {CharsetASCII, 50, trim},
+	"delivery_service":   {CharsetPrintable, 50, trim},
+	"shardkey":           {CharsetIdentifier, 50, trim},
+	"oof_shard":          {CharsetIdentifier, 50, trim},
+
+	"delivery.name":    {CharsetPrintable, 100, trimAndCollapse},
+	"delivery.phone":   {CharsetASCII, 20, trim},
+	"delivery.zip":     {CharsetPrintable, 10, trim},
+	"delivery.city":    {CharsetPrintable, 100, trimAndCollapse},
+	"delivery.address": {CharsetPrintable, 100, trimAndCollapse},
+	"delivery.region":  {CharsetPrintable, 100, trimAndCollapse},
+	"delivery.email":   {CharsetASCII, 100, trim},
+
+	"payment.transaction": {CharsetIdentifier, 100, trim},
+	"payment.request_id":  {CharsetASCII, 100, trim},
+	"payment.currency":    {CharsetAlnum, 10, []string{NormalizeTrim, NormalizeUpper}},
+	"payment.provider":    {CharsetPrintable, 50, trim},
+	"payment.bank":        {CharsetPrintable, 50, trim},
+
+	"items.track_number": {CharsetIdentifier, 50, trim},
+	"items.rid":          {CharsetIdentifier, 100, trim},
+	"items.name":         {CharsetPrintable, 100, trimAndCollapse},
+	"items.size":         {CharsetPrintable, 50, trim},
+	"items.brand":        {CharsetPrintable, 100, trimAndCollapse},
+}
+
+// Действующие политики полей
+var fieldPolicies = DefaultFieldPolicies()
+
+// Копия политик полей по умолчанию
+func DefaultFieldPolicies() map[string]FieldPolicy {
+	policies := make(map[string]FieldPolicy, len(defaultFieldPolicies))
+	for path, p := range defaultFieldPolicies {
+		policies[path] = p
+	}
+	return policies
+}
+
+// Замена политик полей. Заданные в overrides параметры заменяют умолчания поля, незаданные остаются прежними.
+// Вызывается при запуске сервиса до начала обработки заказов.
+func SetFieldPolicies(overrides map[string]FieldPolicy) error {
+	policies := DefaultFieldPolicies()
+	for path, o := range overrides {
+		if err := CheckFieldPolicy(path, o); err != nil {
+			return fmt.Errorf("поле %s: %w", path, err)
+		}
+		p := policies[path]
+		if o.Charset != "" {
+			p.Charset = o.Charset
+		}
+		if o.MaxLength != 0 {
+			p.MaxLength = o.MaxLength
+		}
+		if o.Normalize != nil {
+			p.Normalize = o.Normalize
+		}
+		policies[path] = p
+	}
+	fieldPolicies = policies
+	return nil
+}
+
+// Проверка переопределения o политики поля path: поле должно иметь политику по умолчанию,
+// набор символов и нормализации - быть известными, а длина - не превышать размер столбца в БД
+func CheckFieldPolicy(path string, o FieldPolicy) error {
+	p, ok := defaultFieldPolicies[path]
+	if !ok {
+		return fmt.Errorf("неизвестное поле заказа %q", path)
+	}
+	if _, ok := charsets[o.Charset]; o.Charset != "" && !ok {
+		return fmt.Errorf("неизвестный набор символов %q", o.Charset)
+	}
+	if o.MaxLength < 0 || o.MaxLength > p.MaxLength {
+		return fmt.Errorf("максимальная длина %d вне допустимого диапазона 0-%d", o.MaxLength, p.MaxLength)
+	}
+	for _, name := range o.Normalize {
+		if _, ok := normalizers[name]; !ok {
+			return fmt.Errorf("неизвестная нормализация %q", name)
+		}
+	}
+	return nil
+}
+
+// Рекурсивное применение политик к строковым полям структуры v: сначала нормализация, затем проверка.
+// path - путь к v с индексами массивов для ошибок, key - тот же путь без индексов для поиска политики
+func applyFieldPolicies(v reflect.Value, path, key string) ValidationErrors {
+	var errs ValidationErrors
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := jsonFieldName(t.Field(i))
+			errs = append(errs, applyFieldPolicies(v.Field(i), joinPath(path, name), joinPath(key, name))...)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, applyFieldPolicies(v.Index(i), path+"["+strconv.Itoa(i)+"]", key)...)
+		}
+	case reflect.String:
+		p, ok := fieldPolicies[key]
+		if !ok {
+			return nil
+		}
+		s := v.String()
+		for _, name := range p.Normalize {
+			s = normalizers[name](s)
+		}
+		v.SetString(s)
+
+		if allowed := charsets[p.Charset]; allowed != nil && strings.IndexFunc(s, func(r rune) bool { return !allowed(r) }) >= 0 {
+			errs = append(errs, FieldError{Field: path, Rule: "charset", Message: "содержит символы вне набора " + p.Charset})
+		}
+		if p.MaxLength > 0 && utf8.RuneCountInString(s) > p.MaxLength {
+			errs = append(errs, FieldError{Field: path, Rule: "maxLength", Message: "длина больше " + strconv.Itoa(p.MaxLength) + " символов"})
+		}
+	}
+	return errs
+}
+
+// Путь к вложенному полю
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
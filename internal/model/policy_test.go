@@ -0,0 +1,103 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestFieldPolicies_AllowLegitimateText(t *testing.T) {
+	//Точка с запятой и двойной дефис допустимы в адресах и названиях, запросы к БД параметризованы
+	order := validTestOrder()
+	order.Delivery.Address = "ул. Ленина, д. 5; кв. 3 -- вход со двора"
+	order.Items[0].Name = "Тушь для ресниц; объем"
+	assert.NoError(t, order.Validate())
+}
+
+func TestFieldPolicies_AllowIdentifiers(t *testing.T) {
+	//Идентификаторы в формате UUID и с подчеркиванием или точкой допустимы по умолчанию
+	order := validTestOrder()
+	order.OrderUid = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	order.TrackNumber = "WB_IL.MTEST-TRACK"
+	order.Items[0].TrackNumber = order.TrackNumber
+	order.Payment.Transaction = order.OrderUid
+	order.Items[0].Rid = "ab4219_087a.764ae0-btest"
+	assert.NoError(t, order.Validate())
+}
+
+func TestFieldPolicies_Nested(t *testing.T) {
+	cases := map[string]struct {
+		modify func(o *OrderInfo)
+		field  string
+		rule   string
+	}{
+		"управляющий символ в доставке": {func(o *OrderInfo) { o.Delivery.City = "Kiryat\x00Mozkin" }, "delivery.city", "charset"},
+		"длинный идентификатор оплаты":  {func(o *OrderInfo) { o.Payment.Transaction = strings.Repeat("a", 101) }, "payment.transaction", "maxLength"},
+		"длина в символах, а не байтах": {func(o *OrderInfo) { o.Items[0].Brand = strings.Repeat("я", 101) }, "items[0].brand", "maxLength"},
+		"недопустимый rid товара":       {func(o *OrderInfo) { o.Items[0].Rid = "ab42'19" }, "items[0].rid", "charset"},
+	}
+	for name, c := range cases {
+		order := validTestOrder()
+		c.modify(&order)
+
+		var errs ValidationErrors
+		if !assert.ErrorAs(t, order.Validate(), &errs, name) {
+			continue
+		}
+		var rules []string
+		for _, e := range errs {
+			rules = append(rules, e.Field+" "+e.Rule)
+		}
+		assert.Contains(t, rules, c.field+" "+c.rule, name)
+	}
+
+	//Название бренда из 100 кириллических символов помещается в VARCHAR(100)
+	order := validTestOrder()
+	order.Items[0].Brand = strings.Repeat("я", 100)
+	assert.NoError(t, order.Validate())
+}
+
+func TestFieldPolicies_Normalize(t *testing.T) {
+	order := validTestOrder()
+	order.OrderUid = "  " + order.OrderUid + "\n"
+	order.Delivery.Address = " Ploshad   Mira\t15 "
+	order.Payment.Currency = "usd"
+	require.NoError(t, order.Validate())
+
+	assert.Equal(t, "b563feb7b2b84b6test", order.OrderUid)
+	assert.Equal(t, "Ploshad Mira 15", order.Delivery.Address)
+	assert.Equal(t, "USD", order.Payment.Currency)
+}
+
+func TestSetFieldPolicies(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetFieldPolicies(nil)) })
+
+	//Заданные параметры заменяют умолчания, остальные сохраняются
+	require.NoError(t, SetFieldPolicies(map[string]FieldPolicy{
+		"delivery.zip": {Charset: CharsetDigits},
+	}))
+	assert.Equal(t, FieldPolicy{CharsetDigits, 10, []string{NormalizeTrim}}, fieldPolicies["delivery.zip"])
+
+	order := validTestOrder()
+	order.Delivery.Zip = "SW1A 1AA"
+	var errs ValidationErrors
+	require.ErrorAs(t, order.Validate(), &errs)
+	assert.Equal(t, "delivery.zip", errs[0].Field)
+
+	assert.Error(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.unknown": {MaxLength: 10}}))
+	assert.Error(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.zip": {Charset: "latin"}}))
+	assert.Error(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.zip": {Normalize: []string{"title"}}}))
+
+	//Длина не может превышать размер столбца в БД
+	assert.Error(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.zip": {MaxLength: 11}}))
+	assert.Error(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.zip": {MaxLength: -1}}))
+	assert.NoError(t, SetFieldPolicies(map[string]FieldPolicy{"delivery.zip": {MaxLength: 6}}))
+
+	//Более строгий набор символов для идентификаторов задается явно
+	require.NoError(t, SetFieldPolicies(map[string]FieldPolicy{"order_uid": {Charset: CharsetAlnum}}))
+	order = validTestOrder()
+	order.OrderUid = "0f8fad5b-d9cb-469f"
+	require.ErrorAs(t, order.Validate(), &errs)
+	assert.Equal(t, "order_uid", errs[0].Field)
+}
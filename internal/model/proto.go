@@ -0,0 +1,150 @@
+package model
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"wbl0/internal/model/orderpb"
+)
+
+// Представление заказа в формате protobuf
+func (oi OrderInfo) ToProto() *orderpb.OrderInfo {
+	m := &orderpb.OrderInfo{
+		OrderUid:          oi.OrderUid,
+		TrackNumber:       oi.TrackNumber,
+		Entry:             oi.Entry,
+		Delivery:          oi.Delivery.ToProto(),
+		Payment:           oi.Payment.ToProto(),
+		Items:             ItemsToProto(oi.Items),
+		Locale:            oi.Locale,
+		InternalSignature: oi.InternalSignature,
+		CustomerId:        oi.CustomerId,
+		DeliveryService:   oi.DeliveryService,
+		Shardkey:          oi.Shardkey,
+		SmId:              int32(oi.SmId),
+		OofShard:          oi.OofShard,
+	}
+	if !oi.DateCreated.IsZero() {
+		m.DateCreated = timestamppb.New(oi.DateCreated)
+	}
+	return m
+}
+
+// Заказ из сообщения protobuf. Отсутствующие вложенные сообщения дают нулевые значения полей
+func OrderFromProto(m *orderpb.OrderInfo) OrderInfo {
+	oi := OrderInfo{
+		OrderUid:          m.GetOrderUid(),
+		TrackNumber:       m.GetTrackNumber(),
+		Entry:             m.GetEntry(),
+		Delivery:          deliveryFromProto(m.GetDelivery()),
+		Payment:           paymentFromProto(m.GetPayment()),
+		Locale:            m.GetLocale(),
+		InternalSignature: m.GetInternalSignature(),
+		CustomerId:        m.GetCustomerId(),
+		DeliveryService:   m.GetDeliveryService(),
+		Shardkey:          m.GetShardkey(),
+		SmId:              int(m.GetSmId()),
+		OofShard:          m.GetOofShard(),
+	}
+	for _, item := range m.GetItems() {
+		oi.Items = append(oi.Items, itemFromProto(item))
+	}
+	if m.GetDateCreated() != nil {
+		oi.DateCreated = m.GetDateCreated().AsTime()
+	}
+	return oi
+}
+
+// Представление доставки в формате protobuf
+func (d Delivery) ToProto() *orderpb.Delivery {
+	return &orderpb.Delivery{
+		Name:    d.Name,
+		Phone:   d.Phone,
+		Zip:     d.Zip,
+		City:    d.City,
+		Address: d.Address,
+		Region:  d.Region,
+		Email:   d.Email,
+	}
+}
+
+func deliveryFromProto(m *orderpb.Delivery) Delivery {
+	return Delivery{
+		Name:    m.GetName(),
+		Phone:   m.GetPhone(),
+		Zip:     m.GetZip(),
+		City:    m.GetCity(),
+		Address: m.GetAddress(),
+		Region:  m.GetRegion(),
+		Email:   m.GetEmail(),
+	}
+}
+
+// Представление оплаты в формате protobuf
+func (p Payment) ToProto() *orderpb.Payment {
+	return &orderpb.Payment{
+		Transaction:  p.Transaction,
+		RequestId:    p.RequestId,
+		Currency:     p.Currency,
+		Provider:     p.Provider,
+		Amount:       p.Amount,
+		PaymentDt:    p.PaymentDt,
+		Bank:         p.Bank,
+		DeliveryCost: p.DeliveryCost,
+		GoodsTotal:   p.GoodsTotal,
+		CustomFee:    p.CustomFee,
+	}
+}
+
+func paymentFromProto(m *orderpb.Payment) Payment {
+	return Payment{
+		Transaction:  m.GetTransaction(),
+		RequestId:    m.GetRequestId(),
+		Currency:     m.GetCurrency(),
+		Provider:     m.GetProvider(),
+		Amount:       m.GetAmount(),
+		PaymentDt:    m.GetPaymentDt(),
+		Bank:         m.GetBank(),
+		DeliveryCost: m.GetDeliveryCost(),
+		GoodsTotal:   m.GetGoodsTotal(),
+		CustomFee:    m.GetCustomFee(),
+	}
+}
+
+// Представление товаров заказа в формате protobuf
+func ItemsToProto(items []Item) []*orderpb.Item {
+	if items == nil {
+		return nil
+	}
+	m := make([]*orderpb.Item, len(items))
+	for i, item := range items {
+		m[i] = &orderpb.Item{
+			ChrtId:      item.ChrtId,
+			TrackNumber: item.TrackNumber,
+			Price:       item.Price,
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int32(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  item.TotalPrice,
+			NmId:        item.NmId,
+			Brand:       item.Brand,
+			Status:      int32(item.Status),
+		}
+	}
+	return m
+}
+
+func itemFromProto(m *orderpb.Item) Item {
+	return Item{
+		ChrtId:      m.GetChrtId(),
+		TrackNumber: m.GetTrackNumber(),
+		Price:       m.GetPrice(),
+		Rid:         m.GetRid(),
+		Name:        m.GetName(),
+		Sale:        int(m.GetSale()),
+		Size:        m.GetSize(),
+		TotalPrice:  m.GetTotalPrice(),
+		NmId:        m.GetNmId(),
+		Brand:       m.GetBrand(),
+		Status:      int(m.GetStatus()),
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	"wbl0/internal/model/orderpb"
+)
+
+func TestOrderInfo_ProtoRoundTrip(t *testing.T) {
+	order := validTestOrder()
+
+	data, err := proto.Marshal(order.ToProto())
+	require.NoError(t, err)
+
+	var m orderpb.OrderInfo
+	require.NoError(t, proto.Unmarshal(data, &m))
+	assert.Equal(t, order, OrderFromProto(&m))
+
+	//Пустое сообщение дает пустой заказ, который не проходит валидацию
+	empty := OrderFromProto(&orderpb.OrderInfo{})
+	assert.Equal(t, OrderInfo{}, empty)
+	assert.Error(t, empty.Validate())
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"wbl0/internal/schema"
+)
+
+// Схемы сообщения с заказом: с разрешенными и с запрещенными неизвестными полями
+var (
+	orderSchema       = sync.OnceValue(func() *schema.Schema { return buildOrderSchema(false) })
+	strictOrderSchema = sync.OnceValue(func() *schema.Schema { return buildOrderSchema(true) })
+)
+
+// JSON Schema сообщения с заказом, построенная по структуре OrderInfo.
+// В строгом режиме (strict) неизвестные поля запрещены на всех уровнях вложенности.
+// Схема общая для всех вызовов и не должна изменяться.
+func OrderSchema(strict bool) *schema.Schema {
+	if strict {
+		return strictOrderSchema()
+	}
+	return orderSchema()
+}
+
+// Построение JSON Schema сообщения с заказом. Вложенные структуры описываются в $defs
+func buildOrderSchema(strict bool) *schema.Schema {
+	g := schema.NewGenerator("#/$defs/")
+	g.Strict = strict
+	g.Required = isRequiredField
+	root := g.Schema(reflect.TypeOf(OrderInfo{}))
+
+	return &schema.Schema{
+		SchemaURI: schema.Draft202012,
+		Title:     "order_info",
+		Ref:       root.Ref,
+		Defs:      g.Defs,
+	}
+}
+
+// Обязательными в схеме считаются поля с правилом required в теге validate и вложенные структуры заказа
+func isRequiredField(field reflect.StructField) bool {
+	if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
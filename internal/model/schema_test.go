@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"wbl0/internal/schema"
+)
+
+func TestOrderSchema(t *testing.T) {
+	data, err := json.Marshal(validTestOrder())
+	require.NoError(t, err)
+
+	//Сериализованный заказ соответствует схеме в обоих режимах
+	for _, strict := range []bool{false, true} {
+		s := OrderSchema(strict)
+		assert.NoError(t, schema.ValidateJSON(s, s.Defs, data), "strict=%v", strict)
+	}
+
+	//Обязательные поля определяются по тегам validate
+	order := OrderSchema(false).Defs["OrderInfo"]
+	assert.Contains(t, order.Required, "order_uid")
+	assert.Contains(t, order.Required, "delivery")
+	assert.NotContains(t, order.Required, "internal_signature")
+	assert.NotContains(t, OrderSchema(false).Defs["Payment"].Required, "request_id")
+
+	//Неизвестное поле доставки отклоняется только в строгом режиме
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	raw["delivery"].(map[string]any)["floor"] = 3
+	data, err = json.Marshal(raw)
+	require.NoError(t, err)
+
+	assert.NoError(t, schema.ValidateJSON(OrderSchema(false), OrderSchema(false).Defs, data))
+	err = schema.ValidateJSON(OrderSchema(true), OrderSchema(true).Defs, data)
+	var errs schema.Errors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "/delivery/floor", errs[0].Path)
+
+	//Отсутствующее обязательное поле отклоняется в любом режиме
+	delete(raw, "order_uid")
+	delete(raw["delivery"].(map[string]any), "floor")
+	data, err = json.Marshal(raw)
+	require.NoError(t, err)
+	assert.Error(t, schema.ValidateJSON(OrderSchema(false), OrderSchema(false).Defs, data))
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Статусы товаров заказа
+const (
+	StatusAccepted  = 202
+	StatusAssembled = 203
+	StatusShipped   = 204
+	StatusDelivered = 205
+	StatusCancelled = 400
+	StatusReturned  = 401
+)
+
+// Допустимые переходы между статусами. Отмененный и возвращенный товары больше не меняют статус
+var statusTransitions = map[int][]int{
+	StatusAccepted:  {StatusAssembled, StatusCancelled},
+	StatusAssembled: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {StatusReturned},
+	StatusCancelled: nil,
+	StatusReturned:  nil,
+}
+
+// Ошибка при попытке перевести товар в статус, недопустимый из текущего
+var ErrInvalidTransition = errors.New("недопустимый переход статуса")
+
+// Проверка того, что status - известный статус
+func IsKnownStatus(status int) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// Проверка допустимости перехода из статуса from в статус to.
+// Возвращает ошибку, для которой errors.Is(err, ErrInvalidTransition) == true, если переход недопустим.
+func ValidateTransition(from, to int) error {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d -> %d", ErrInvalidTransition, from, to)
+}
+
+// Событие изменения статуса товаров заказа.
+// Если ChrtId не задан, статус меняется у всех товаров заказа.
+type StatusUpdate struct {
+	OrderUid  string    `json:"order_uid" validate:"required,max=50"`
+	ChrtId    int64     `json:"chrt_id,omitempty"`
+	Status    int       `json:"status" validate:"required"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// Проверка события изменения статуса
+func (su *StatusUpdate) Validate() error {
+	if err := validate.Struct(su); err != nil {
+		return err
+	}
+	if !IsKnownStatus(su.Status) {
+		return fmt.Errorf("неизвестный статус %d", su.Status)
+	}
+	return nil
+}
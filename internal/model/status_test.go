@@ -0,0 +1,28 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateTransition(t *testing.T) {
+	assert.NoError(t, ValidateTransition(StatusAccepted, StatusAssembled))
+	assert.NoError(t, ValidateTransition(StatusAssembled, StatusCancelled))
+	assert.NoError(t, ValidateTransition(StatusDelivered, StatusReturned))
+
+	assert.ErrorIs(t, ValidateTransition(StatusAccepted, StatusDelivered), ErrInvalidTransition)
+	assert.ErrorIs(t, ValidateTransition(StatusShipped, StatusCancelled), ErrInvalidTransition)
+	assert.ErrorIs(t, ValidateTransition(StatusCancelled, StatusAccepted), ErrInvalidTransition)
+	assert.ErrorIs(t, ValidateTransition(StatusAccepted, StatusAccepted), ErrInvalidTransition)
+}
+
+func TestStatusUpdateValidation(t *testing.T) {
+	valid := StatusUpdate{OrderUid: "b563feb7b2b84b6test", Status: StatusShipped}
+	assert.NoError(t, valid.Validate())
+
+	noOrder := StatusUpdate{Status: StatusShipped}
+	assert.Error(t, noOrder.Validate())
+
+	unknown := StatusUpdate{OrderUid: "b563feb7b2b84b6test", Status: 999}
+	assert.Error(t, unknown.Validate())
+}
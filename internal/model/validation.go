@@ -1,35 +1,142 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
-// Кастомная валидация OrderInfo
-func validateNoSpecialChars(sl validator.StructLevel) {
+// Нарушение правила валидации поля заказа.
+// Field - путь к полю из JSON-имен, например payment.amount или items[0].total_price
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	//Ошибка, не относящаяся к конкретному полю, например некорректный JSON
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Список нарушений правил валидации
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Добавление ошибок валидатора err к списку errs. Ошибки другого типа возвращаются как есть
+func appendFieldErrors(errs ValidationErrors, err error) (ValidationErrors, error) {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return errs, err
+	}
+
+	for _, fe := range fieldErrs {
+		//Первый сегмент пути - имя проверяемой структуры
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		errs = append(errs, FieldError{Field: field, Rule: fe.Tag(), Message: fieldErrorMessage(fe)})
+	}
+	return errs, nil
+}
+
+// Описание нарушения правила для FieldError
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "обязательное поле"
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return "должно содержать не меньше " + fe.Param() + " элементов"
+		}
+		return "должно быть не меньше " + fe.Param()
+	case "max":
+		return "должно быть не больше " + fe.Param()
+	case "email":
+		return "некорректный адрес электронной почты"
+	case "e164":
+		return "телефон должен быть в формате E.164, например +79990000000"
+	case "iso4217":
+		return "неизвестный код валюты ISO 4217"
+	case "bcp47_language_tag":
+		return "некорректный код локали BCP 47"
+	case "amountSum":
+		return "должно быть равно goods_total + delivery_cost + custom_fee = " + fe.Param()
+	case "goodsTotal":
+		return "должно быть равно сумме total_price товаров = " + fe.Param()
+	case "totalPrice":
+		return "должно быть равно price с учетом скидки sale = " + fe.Param()
+	case "orderTrackNumber":
+		return "должно совпадать с track_number заказа " + fe.Param()
+	case "knownStatus":
+		return "неизвестный статус товара"
+	}
+	return fe.Error()
+}
+
+// Бизнес-правила заказа: сумма товаров равна goods_total, трек-номер товаров совпадает с трек-номером заказа
+func validateOrderRules(sl validator.StructLevel) {
 	order := sl.Current().Interface().(OrderInfo)
-	orderType := reflect.TypeOf(order)
-	orderValue := reflect.ValueOf(order)
-
-	//Запрет спецсимволов на пример точки с запятой и двойного дефиса
-	for i := 0; i < orderValue.NumField(); i++ {
-		fValue := orderValue.Field(i)
-		if fValue.Kind() == reflect.String {
-			fName := orderType.Field(i).Name
-			fStr := fValue.String()
-
-			for _, char := range []string{";", "--"} {
-				if strings.Contains(fStr, char) {
-					sl.ReportError(fValue, fName, "noSpecialChars", "", "")
-				}
-
-			}
+	var goodsTotal int64
+	for i, item := range order.Items {
+		goodsTotal += item.TotalPrice
+		if item.TrackNumber != order.TrackNumber {
+			sl.ReportError(item.TrackNumber, fmt.Sprintf("items[%d].track_number", i), fmt.Sprintf("Items[%d].TrackNumber", i), "orderTrackNumber", order.TrackNumber)
 		}
 	}
+	if len(order.Items) > 0 && goodsTotal != order.Payment.GoodsTotal {
+		sl.ReportError(order.Payment.GoodsTotal, "payment.goods_total", "Payment.GoodsTotal", "goodsTotal", strconv.FormatInt(goodsTotal, 10))
+	}
+}
+
+// Сумма платежа складывается из стоимости товаров, доставки и пошлины
+func validatePaymentRules(sl validator.StructLevel) {
+	payment := sl.Current().Interface().(Payment)
+	sum := payment.GoodsTotal + payment.DeliveryCost + payment.CustomFee
+	if payment.Amount != sum {
+		sl.ReportError(payment.Amount, "amount", "Amount", "amountSum", strconv.FormatInt(sum, 10))
+	}
+}
+
+// Итоговая цена товара - цена со скидкой sale процентов. Допускается округление до целого в любую сторону.
+// Статус товара должен быть известным, иначе событие изменения статуса не сможет перевести товар из него
+func validateItemRules(sl validator.StructLevel) {
+	item := sl.Current().Interface().(Item)
+	discounted := item.Price * int64(100-item.Sale)
+	if diff := item.TotalPrice*100 - discounted; diff <= -100 || diff >= 100 {
+		sl.ReportError(item.TotalPrice, "total_price", "TotalPrice", "totalPrice", strconv.FormatInt(discounted/100, 10))
+	}
+	//Нулевой статус отклоняется правилом required
+	if item.Status != 0 && !IsKnownStatus(item.Status) {
+		sl.ReportError(item.Status, "status", "Status", "knownStatus", "")
+	}
+}
+
+// Имя поля в JSON, а если тега json нет - имя поля структуры
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
 }
 
-// Регистрация кастомного валидатора для структуры OrderInfo
+// Регистрация кастомных валидаторов для структур заказа
 func init() {
-	validate.RegisterStructValidation(validateNoSpecialChars, OrderInfo{})
+	//Пути к полям в ошибках строятся из JSON-имен, как в теле сообщения
+	validate.RegisterTagNameFunc(jsonFieldName)
+	validate.RegisterStructValidation(validateOrderRules, OrderInfo{})
+	validate.RegisterStructValidation(validatePaymentRules, Payment{})
+	validate.RegisterStructValidation(validateItemRules, Item{})
 }
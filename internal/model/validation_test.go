@@ -3,11 +3,12 @@ package model
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
-func TestOrderInfoValidation(t *testing.T) {
-	// Корректные данные
-	validOrder := OrderInfo{
+// Корректный заказ для тестов валидации
+func validTestOrder() OrderInfo {
+	return OrderInfo{
 		OrderUid:    "b563feb7b2b84b6test",
 		TrackNumber: "WBILMTESTTRACK",
 		Entry:       "WBIL",
@@ -53,9 +54,14 @@ func TestOrderInfoValidation(t *testing.T) {
 		DeliveryService:   "meest",
 		Shardkey:          "9",
 		SmId:              99,
-		DateCreated:       "2021-11-26T06:22:19Z",
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
 		OofShard:          "1",
 	}
+}
+
+func TestOrderInfoValidation(t *testing.T) {
+	// Корректные данные
+	validOrder := validTestOrder()
 	// Некорректные данные, включающие в себя SQL-инъекцию в поле OrderUid
 	invalidOrder := OrderInfo{
 		OrderUid:    "1'; DROP TABLE orders; --",
@@ -103,7 +109,7 @@ func TestOrderInfoValidation(t *testing.T) {
 		DeliveryService:   "meest",
 		Shardkey:          "9",
 		SmId:              99,
-		DateCreated:       "2021-11-26T06:22:19Z",
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
 		OofShard:          "1",
 	}
 
@@ -113,3 +119,59 @@ func TestOrderInfoValidation(t *testing.T) {
 	// Проверяем валидацию для невалидного заказа
 	assert.Error(t, invalidOrder.Validate())
 }
+
+func TestFieldError_Error(t *testing.T) {
+	assert.Equal(t, "delivery.zip: обязательное поле", FieldError{Field: "delivery.zip", Message: "обязательное поле"}.Error())
+	//Ошибка всего сообщения выводится без пустого префикса поля
+	assert.Equal(t, "некорректный JSON", FieldError{Message: "некорректный JSON"}.Error())
+}
+
+func TestOrderInfoValidation_Rules(t *testing.T) {
+	cases := map[string]struct {
+		modify func(o *OrderInfo)
+		field  string
+		rule   string
+	}{
+		"обязательное поле":    {func(o *OrderInfo) { o.CustomerId = "" }, "customer_id", "required"},
+		"email":                {func(o *OrderInfo) { o.Delivery.Email = "test" }, "delivery.email", "email"},
+		"телефон":              {func(o *OrderInfo) { o.Delivery.Phone = "8 999 000-00-00" }, "delivery.phone", "e164"},
+		"валюта":               {func(o *OrderInfo) { o.Payment.Currency = "XXY" }, "payment.currency", "iso4217"},
+		"локаль":               {func(o *OrderInfo) { o.Locale = "english!" }, "locale", "bcp47_language_tag"},
+		"без товаров":          {func(o *OrderInfo) { o.Items = nil }, "items", "required"},
+		"сумма платежа":        {func(o *OrderInfo) { o.Payment.Amount++ }, "payment.amount", "amountSum"},
+		"стоимость товаров":    {func(o *OrderInfo) { o.Payment.GoodsTotal++; o.Payment.Amount++ }, "payment.goods_total", "goodsTotal"},
+		"цена со скидкой":      {func(o *OrderInfo) { o.Items[0].Sale = 10 }, "items[0].total_price", "totalPrice"},
+		"трек-номер товара":    {func(o *OrderInfo) { o.Items[0].TrackNumber = "OTHER" }, "items[0].track_number", "orderTrackNumber"},
+		"статус товара":        {func(o *OrderInfo) { o.Items[0].Status = 999 }, "items[0].status", "knownStatus"},
+		"недопустимые символы": {func(o *OrderInfo) { o.Shardkey = "9;" }, "shardkey", "charset"},
+	}
+	for name, c := range cases {
+		order := validTestOrder()
+		c.modify(&order)
+
+		var errs ValidationErrors
+		if !assert.ErrorAs(t, order.Validate(), &errs, name) {
+			continue
+		}
+		var rules []string
+		for _, e := range errs {
+			assert.NotEmpty(t, e.Message, name)
+			rules = append(rules, e.Field+" "+e.Rule)
+		}
+		assert.Contains(t, rules, c.field+" "+c.rule, name)
+	}
+}
+
+func TestOrderInfoValidation_TotalPriceRounding(t *testing.T) {
+	//453 со скидкой 30% - 317.1, допускается как 317, так и 318
+	order := validTestOrder()
+	order.Items[0].TotalPrice = 318
+	order.Payment.GoodsTotal = 318
+	order.Payment.Amount = 1818
+	assert.NoError(t, order.Validate())
+
+	order.Items[0].TotalPrice = 319
+	order.Payment.GoodsTotal = 319
+	order.Payment.Amount = 1819
+	assert.Error(t, order.Validate())
+}
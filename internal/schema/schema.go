@@ -0,0 +1,147 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Идентификатор диалекта JSON Schema 2020-12 для поля $schema
+const Draft202012 = "https://json-schema.org/draft/2020-12/schema"
+
+// Схема JSON-значения (подмножество JSON Schema 2020-12, которое также используется в OpenAPI 3.1)
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Генератор схем по Go-типам.
+// Схемы структур добавляются в Defs под именем типа, а в месте использования заменяются ссылкой RefPrefix+имя.
+// Имена свойств берутся из JSON-тегов, поля без omitempty считаются обязательными, если не задана функция Required.
+type Generator struct {
+	RefPrefix string
+	Defs      map[string]*Schema
+	//Запрет неизвестных свойств в схемах объектов (additionalProperties: false)
+	Strict bool
+	//Признак обязательного поля структуры. Если не задан, обязательны поля без omitempty
+	Required func(field reflect.StructField) bool
+	names    map[reflect.Type]string
+}
+
+// Создание генератора схем со ссылками вида refPrefix+имя, например "#/components/schemas/"
+func NewGenerator(refPrefix string) *Generator {
+	return &Generator{
+		RefPrefix: refPrefix,
+		Defs:      map[string]*Schema{},
+		names:     map[reflect.Type]string{},
+	}
+}
+
+// Регистрация схемы структуры t под именем name. Возвращает ссылку на схему
+func (g *Generator) Define(name string, t reflect.Type) *Schema {
+	if _, ok := g.names[t]; !ok {
+		g.names[t] = name
+		g.Defs[name] = g.structSchema(t)
+	}
+	return &Schema{Ref: g.RefPrefix + g.names[t]}
+}
+
+// Схема для типа t
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		if name, ok := g.names[t]; ok {
+			return &Schema{Ref: g.RefPrefix + name}
+		}
+		return g.Define(t.Name(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		//nil-срез кодируется в JSON как null
+		return &Schema{Type: []string{"array", "null"}, Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	}
+	return &Schema{}
+}
+
+// Схема объекта для структуры t
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if g.Strict {
+		s.AdditionalProperties = new(bool)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitempty, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		s.Properties[name] = g.Schema(field.Type)
+		required := !omitempty
+		if g.Required != nil {
+			required = g.Required(field)
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// Имя поля в JSON и признак omitempty. ok == false, если поле не сериализуется
+func jsonName(field reflect.StructField) (name string, omitempty bool, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, true
+}
@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Name string `json:"name"`
+}
+
+type testDoc struct {
+	ID      int64      `json:"id"`
+	Created time.Time  `json:"created"`
+	Note    string     `json:"note,omitempty"`
+	Items   []testItem `json:"items"`
+	hidden  string
+	Skipped string `json:"-"`
+}
+
+func TestGenerator_Schema(t *testing.T) {
+	g := NewGenerator("#/defs/")
+	s := g.Schema(reflect.TypeOf(testDoc{}))
+	assert.Equal(t, "#/defs/testDoc", s.Ref)
+
+	doc := g.Defs["testDoc"]
+	require.NotNil(t, doc)
+	assert.Equal(t, []string{"id", "created", "items"}, doc.Required)
+	assert.Len(t, doc.Properties, 4)
+	assert.Equal(t, "date-time", doc.Properties["created"].Format)
+	assert.Equal(t, "#/defs/testItem", doc.Properties["items"].Items.Ref)
+	assert.Contains(t, g.Defs, "testItem")
+}
+
+func TestValidateJSON(t *testing.T) {
+	g := NewGenerator("#/defs/")
+	s := g.Schema(reflect.TypeOf(testDoc{}))
+
+	//Значение, полученное сериализацией структуры, соответствует ее схеме
+	data, err := json.Marshal(testDoc{ID: 1, Created: time.Now(), Items: []testItem{{Name: "a"}}})
+	require.NoError(t, err)
+	assert.NoError(t, ValidateJSON(s, g.Defs, data))
+
+	//nil-срез кодируется как null
+	assert.NoError(t, ValidateJSON(s, g.Defs, []byte(`{"id":1,"created":"2021-11-26T06:22:19Z","items":null}`)))
+
+	err = ValidateJSON(s, g.Defs, []byte(`{"id":1.5,"created":"yesterday","items":[{"name":1}]}`))
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	paths := make([]string, len(errs))
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+	assert.ElementsMatch(t, []string{"/created", "/id", "/items/0/name"}, paths)
+
+	//В строгом режиме неизвестные поля запрещены
+	strict := false
+	g.Defs["testItem"].AdditionalProperties = &strict
+	assert.Error(t, ValidateJSON(s, g.Defs, []byte(`{"id":1,"created":"2021-11-26T06:22:19Z","items":[{"name":"a","extra":1}]}`)))
+}
+
+func TestGenerator_StrictAndRequired(t *testing.T) {
+	g := NewGenerator("#/$defs/")
+	g.Strict = true
+	g.Required = func(field reflect.StructField) bool {
+		return field.Name == "ID"
+	}
+	s := &Schema{SchemaURI: Draft202012, Ref: g.Schema(reflect.TypeOf(testDoc{})).Ref, Defs: g.Defs}
+
+	doc := g.Defs["testDoc"]
+	assert.Equal(t, []string{"id"}, doc.Required)
+	require.NotNil(t, doc.AdditionalProperties)
+	assert.False(t, *doc.AdditionalProperties)
+
+	//Неизвестные поля отклоняются на любом уровне вложенности
+	assert.NoError(t, ValidateJSON(s, s.Defs, []byte(`{"id":1}`)))
+	assert.Error(t, ValidateJSON(s, s.Defs, []byte(`{"id":1,"extra":true}`)))
+	assert.Error(t, ValidateJSON(s, s.Defs, []byte(`{"id":1,"items":[{"name":"a","extra":1}]}`)))
+}
@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Несоответствие значения схеме. Path - путь к значению в формате JSON Pointer
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Список несоответствий значения схеме
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Проверка JSON-документа data на соответствие схеме s.
+// defs используется для разрешения ссылок $ref, имя схемы берется из последнего сегмента ссылки.
+// Возвращает Errors со всеми найденными несоответствиями или nil.
+func ValidateJSON(s *Schema, defs map[string]*Schema, data []byte) error {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return Errors{{Path: "/", Message: "некорректный JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return Errors{{Path: "/", Message: "после JSON-значения есть лишние данные"}}
+	}
+	return Validate(s, defs, value)
+}
+
+// Проверка значения, полученного из json.Unmarshal в any, на соответствие схеме s
+func Validate(s *Schema, defs map[string]*Schema, value any) error {
+	v := validator{defs: defs}
+	v.validate(s, value, "")
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// Сообщение о поле, которого нет в схеме, запрещающей дополнительные поля
+const UnknownFieldMessage = "неизвестное поле"
+
+// Скомпилированные регулярные выражения из поля pattern схем
+var patterns sync.Map
+
+// Состояние проверки значения: схемы для разрешения ссылок и найденные несоответствия
+type validator struct {
+	defs map[string]*Schema
+	errs Errors
+}
+
+// Регистрация несоответствия значения по пути path
+func (v *validator) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "/"
+	}
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Рекурсивная проверка значения value по пути path на соответствие схеме s
+func (v *validator) validate(s *Schema, value any, path string) {
+	if s.Ref != "" {
+		name := s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+		def, ok := v.defs[name]
+		if !ok {
+			v.fail(path, "неизвестная ссылка %s", s.Ref)
+			return
+		}
+		s = def
+	}
+
+	if types := typeList(s.Type); len(types) > 0 {
+		actual := jsonType(value)
+		if !matchesType(types, actual, value) {
+			v.fail(path, "ожидается тип %s, получен %s", strings.Join(types, " или "), actual)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		v.fail(path, "значение не входит в список допустимых")
+	}
+
+	switch val := value.(type) {
+	case string:
+		v.validateString(s, val, path)
+	case json.Number, float64:
+		if s.Minimum != nil && toFloat(val) < *s.Minimum {
+			v.fail(path, "значение меньше %v", *s.Minimum)
+		}
+		if s.Maximum != nil && toFloat(val) > *s.Maximum {
+			v.fail(path, "значение больше %v", *s.Maximum)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+	case map[string]any:
+		v.validateObject(s, val, path)
+	}
+}
+
+// Проверка ограничений строки: длины, шаблона и формата
+func (v *validator) validateString(s *Schema, val string, path string) {
+	length := utf8.RuneCountInString(val)
+	if s.MinLength != nil && length < *s.MinLength {
+		v.fail(path, "длина меньше %d", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		v.fail(path, "длина больше %d", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := compilePattern(s.Pattern)
+		if err != nil || !re.MatchString(val) {
+			v.fail(path, "значение не соответствует шаблону %s", s.Pattern)
+		}
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, val); err != nil {
+			v.fail(path, "значение не является датой в формате RFC 3339")
+		}
+	}
+}
+
+// Проверка объекта: обязательных, известных и неизвестных полей
+func (v *validator) validateObject(s *Schema, val map[string]any, path string) {
+	for _, name := range s.Required {
+		if _, ok := val[name]; !ok {
+			v.fail(path+"/"+name, "обязательное поле отсутствует")
+		}
+	}
+
+	names := make([]string, 0, len(val))
+	for name := range val {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if ok {
+			v.validate(prop, val[name], path+"/"+name)
+		} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			v.fail(path+"/"+name, UnknownFieldMessage)
+		}
+	}
+}
+
+// Список допустимых типов из поля type схемы (строка или массив строк)
+func typeList(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// Тип JSON-значения в терминах JSON Schema
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Проверка соответствия фактического типа значения одному из допустимых типов
+func matchesType(types []string, actual string, value any) bool {
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+		if t == "integer" && isInteger(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверка того, что число является целым
+func isInteger(value any) bool {
+	switch n := value.(type) {
+	case json.Number:
+		_, err := n.Int64()
+		return err == nil
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+// Проверка вхождения значения в список допустимых значений
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Преобразование JSON-числа к float64
+func toFloat(value any) float64 {
+	switch n := value.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	}
+	return math.NaN()
+}
+
+// Получение скомпилированного регулярного выражения для шаблона pattern
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
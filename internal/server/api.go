@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"wbl0/internal/broker"
+	"wbl0/internal/model"
+)
+
+// Префикс REST API версии 1
+const apiV1Prefix = "/api/v1"
+
+// Маршрутизация запросов к REST API версии 1.
+// Описание API в формате OpenAPI 3.1 доступно по адресу /api/v1/openapi.json,
+// JSON Schema сообщения с заказом - по адресу /api/v1/schemas/order_info.json.
+func (hs *HTTPServer) serveAPIv1(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, apiV1Prefix), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "openapi.json":
+		hs.handleOpenAPI(w, r)
+	case path == "schemas/order_info.json":
+		hs.handleOrderSchema(w, r)
+	case path == "orders":
+		hs.handleListOrders(w, r)
+	case path == "orders/search":
+		hs.handleSearchOrders(w, r)
+	case len(parts) == 2 && parts[0] == "orders":
+		hs.handleGetOrderPart(w, r, parts[1], func(order model.OrderInfo) any {
+			return order
+		})
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "items":
+		hs.handleGetOrderPart(w, r, parts[1], func(order model.OrderInfo) any {
+			if order.Items == nil {
+				return []model.Item{}
+			}
+			return order.Items
+		})
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "delivery":
+		hs.handleGetOrderPart(w, r, parts[1], func(order model.OrderInfo) any {
+			return order.Delivery
+		})
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "payment":
+		hs.handleGetOrderPart(w, r, parts[1], func(order model.OrderInfo) any {
+			return order.Payment
+		})
+	case len(parts) == 3 && parts[0] == "orders" && parts[2] == "history":
+		hs.handleOrderHistory(w, r, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "Ресурс не найден")
+	}
+}
+
+// Обработка запроса на получение заказа или его части, которую выбирает функция part.
+// При заголовке Accept: application/x-protobuf результат возвращается в формате protobuf
+func (hs *HTTPServer) handleGetOrderPart(w http.ResponseWriter, r *http.Request, id string, part func(order model.OrderInfo) any) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	data, err := hs.srv.GetDataById(id)
+	if err != nil {
+		hs.writeServiceError(w, r, err)
+		return
+	}
+
+	writeOrderData(w, r, part(data))
+}
+
+// Обработка запроса на получение описания API в формате OpenAPI
+func (hs *HTTPServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument())
+}
+
+// Обработка запроса на получение JSON Schema сообщения с заказом в режиме проверки сообщений брокера
+func (hs *HTTPServer) handleOrderSchema(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(model.OrderSchema(broker.StrictSchema()))
+}
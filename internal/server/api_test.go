@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"wbl0/internal/broker"
+	"wbl0/internal/cache"
+	"wbl0/internal/database"
+	"wbl0/internal/model"
+	"wbl0/internal/model/orderpb"
+	"wbl0/internal/schema"
+	"wbl0/internal/service"
+	"wbl0/testutils"
+)
+
+// Часть документа OpenAPI, необходимая для проверки ответов
+type openAPISpec struct {
+	Paths map[string]map[string]struct {
+		Responses map[string]struct {
+			Content map[string]struct {
+				Schema *schema.Schema `json:"schema"`
+			} `json:"content"`
+		} `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema.Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Получение документа OpenAPI от обработчика
+func fetchOpenAPISpec(t *testing.T, handler http.Handler) openAPISpec {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var spec openAPISpec
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &spec))
+	return spec
+}
+
+// Выполнение запроса и проверка ответа на соответствие схеме из документа OpenAPI для шаблона пути template
+func checkContract(t *testing.T, handler http.Handler, spec openAPISpec, template, url string, status int) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+	require.Equal(t, status, recorder.Code, url)
+
+	op, ok := spec.Paths[template]["get"]
+	require.True(t, ok, template)
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	require.True(t, ok, "%s: ответ %d не описан", template, status)
+
+	s := resp.Content["application/json"].Schema
+	require.NotNil(t, s)
+	assert.NoError(t, schema.ValidateJSON(s, spec.Components.Schemas, recorder.Body.Bytes()), url)
+}
+
+func TestAPIv1_OrderContract(t *testing.T) {
+	//Заказ берется из кэша, поэтому БД не требуется
+	c := cache.NewCache()
+	c.SetById(testutils.TestOrder.OrderUid, testutils.TestOrder)
+	handler := NewHTTPServer(service.NewService(nil, c))
+	spec := fetchOpenAPISpec(t, handler)
+
+	id := testutils.TestOrder.OrderUid
+	checkContract(t, handler, spec, "/orders/{id}", "/api/v1/orders/"+id, http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}/items", "/api/v1/orders/"+id+"/items", http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}/delivery", "/api/v1/orders/"+id+"/delivery", http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}/payment", "/api/v1/orders/"+id+"/payment", http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}", "/api/v1/orders/"+strings.Repeat("a", 51), http.StatusBadRequest)
+	checkContract(t, handler, spec, "/orders", "/api/v1/orders?limit=0", http.StatusBadRequest)
+	checkContract(t, handler, spec, "/orders/search", "/api/v1/orders/search", http.StatusBadRequest)
+
+	//Неизвестный ресурс
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestAPIv1_ListContract(t *testing.T) {
+	// Подготовка к тестированию HTTP-сервера
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	s := service.NewService(database.NewDB(db), cache.NewCache())
+	assert.NoError(t, s.SaveData(context.Background(), testutils.TestOrder, model.Source{}))
+	handler := NewHTTPServer(s)
+	spec := fetchOpenAPISpec(t, handler)
+
+	checkContract(t, handler, spec, "/orders", "/api/v1/orders?limit=1", http.StatusOK)
+	checkContract(t, handler, spec, "/orders/search", "/api/v1/orders/search?customer_id="+testutils.TestOrder.CustomerId, http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}", "/api/v1/orders/unknown", http.StatusNotFound)
+	checkContract(t, handler, spec, "/orders/{id}/history", "/api/v1/orders/"+testutils.TestOrder.OrderUid+"/history", http.StatusOK)
+	checkContract(t, handler, spec, "/orders/{id}/history", "/api/v1/orders/unknown/history", http.StatusNotFound)
+}
+
+func TestAPIv1_OrderSchema(t *testing.T) {
+	order, err := json.Marshal(testutils.TestOrder)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { broker.SetStrictSchema(false) })
+	for _, strict := range []bool{false, true} {
+		//Схема публикуется в режиме проверки сообщений брокера
+		broker.SetStrictSchema(strict)
+		handler := NewHTTPServer(nil)
+		spec := fetchOpenAPISpec(t, handler)
+		assert.Contains(t, spec.Paths, "/schemas/order_info.json")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/schemas/order_info.json", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/schema+json", recorder.Header().Get("Content-Type"))
+
+		//Опубликованная схема принимает корректный заказ и запрещает неизвестные поля только в строгом режиме
+		var s schema.Schema
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &s))
+		assert.Equal(t, schema.Draft202012, s.SchemaURI)
+		assert.NoError(t, schema.ValidateJSON(&s, s.Defs, order))
+		assert.Equal(t, strict, s.Defs["OrderInfo"].AdditionalProperties != nil)
+	}
+}
+
+func TestAcceptsProtobuf(t *testing.T) {
+	cases := map[string]bool{
+		"":                                  false,
+		"application/json":                  false,
+		"application/x-protobuf":            true,
+		"application/protobuf":              true,
+		"application/x-protobuf;q=0":        false,
+		"*/*":                               false,
+		"application/x-protobuf, */*;q=0.1": true,
+		"application/x-protobuf;q=0.5, */*": false,
+		"application/json, application/x-protobuf;q=0.1":          false,
+		"application/json;q=0.5, application/x-protobuf;q=0.8":    true,
+		"application/x-protobuf, application/json":                true,
+		"application/json, application/x-protobuf":                false,
+		"application/json;q=0, */*, application/x-protobuf;q=0.1": true,
+	}
+	for accept, want := range cases {
+		r := httptest.NewRequest("GET", "/api/v1/orders", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		assert.Equal(t, want, acceptsProtobuf(r), accept)
+	}
+}
+
+func TestAPIv1_Protobuf(t *testing.T) {
+	c := cache.NewCache()
+	c.SetById(testutils.TestOrder.OrderUid, testutils.TestOrder)
+	handler := NewHTTPServer(service.NewService(nil, c))
+
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		rq := httptest.NewRequest("GET", url, nil)
+		rq.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, rq)
+		require.Equal(t, http.StatusOK, recorder.Code, url)
+		return recorder
+	}
+
+	id := testutils.TestOrder.OrderUid
+	for _, url := range []string{"/api/v1/orders/" + id, "/get_data?id=" + id} {
+		recorder := get(url, "application/x-protobuf")
+		assert.Equal(t, contentTypeProtobuf, recorder.Header().Get("Content-Type"))
+		var order orderpb.OrderInfo
+		require.NoError(t, proto.Unmarshal(recorder.Body.Bytes(), &order))
+		assert.Equal(t, testutils.TestOrder, model.OrderFromProto(&order))
+	}
+
+	//Части заказа
+	var items orderpb.ItemList
+	require.NoError(t, proto.Unmarshal(get("/api/v1/orders/"+id+"/items", "application/protobuf").Body.Bytes(), &items))
+	assert.Len(t, items.Items, len(testutils.TestOrder.Items))
+	var payment orderpb.Payment
+	require.NoError(t, proto.Unmarshal(get("/api/v1/orders/"+id+"/payment", contentTypeProtobuf).Body.Bytes(), &payment))
+	assert.Equal(t, testutils.TestOrder.Payment.Transaction, payment.Transaction)
+
+	//Без заголовка Accept и с форматом, исключенным через q=0, возвращается JSON
+	for _, accept := range []string{"", "application/x-protobuf;q=0, application/json"} {
+		recorder := get("/api/v1/orders/"+id, accept)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.True(t, json.Valid(recorder.Body.Bytes()))
+	}
+
+	//Страница списка заказов
+	m, ok := protoMessage(ordersPage{Orders: []model.OrderInfo{testutils.TestOrder}, NextCursor: "abc"})
+	require.True(t, ok)
+	page := m.(*orderpb.OrdersPage)
+	assert.Len(t, page.Orders, 1)
+	assert.Equal(t, "abc", page.NextCursor)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"wbl0/internal/service"
+)
+
+// Коды ошибок в ответах HTTP-сервера
+const (
+	codeInvalidID        = "invalid_id"
+	codeInvalidParameter = "invalid_parameter"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeInternal         = "internal_error"
+)
+
+// Тело ответа с ошибкой
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Запись ответа с ошибкой в формате JSON
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Code: code, Message: message})
+}
+
+// Запись ответа с ошибкой, полученной от сервиса.
+// Некорректный идентификатор соответствует статусу 400, отсутствующий заказ - 404, остальные ошибки - 500
+// и регистрируются в журнале, так как их текст не возвращается клиенту.
+func (hs *HTTPServer) writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidID):
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Некорректный идентификатор заказа")
+	case errors.Is(err, service.ErrNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, "Заказ не найден")
+	default:
+		hs.logger.ErrorContext(r.Context(), "Ошибка при получении данных", "error", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Ошибка при получении данных")
+	}
+}
+
+// Проверка метода запроса. Если метод не совпадает с method, записывается ответ 405 и возвращается false
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Метод не поддерживается")
+	return false
+}
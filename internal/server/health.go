@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Максимальное время выполнения проверок готовности
+const readinessTimeout = 2 * time.Second
+
+// Проверка готовности зависимости сервиса. Возвращает ошибку, если зависимость недоступна
+type ReadinessCheck func(ctx context.Context) error
+
+// Именованная проверка готовности
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// Результат проверки одной зависимости
+type checkStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Тело ответа /healthz и /readyz
+type healthBody struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkStatus `json:"checks,omitempty"`
+}
+
+// Статусы в ответах /healthz и /readyz
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	statusError       = "error"
+)
+
+// Добавление проверки готовности, результат которой возвращается в /readyz под именем name
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(hs *HTTPServer) {
+		hs.checks = append(hs.checks, namedCheck{name: name, check: check})
+	}
+}
+
+// Обработка запроса проверки жизнеспособности. Сервер, который отвечает на запросы, считается живым
+func (hs *HTTPServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeHealth(w, http.StatusOK, healthBody{Status: statusOK})
+}
+
+// Обработка запроса проверки готовности.
+// Все проверки выполняются параллельно. Если хотя бы одна из них не прошла, возвращается статус 503.
+func (hs *HTTPServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	results := make([]checkStatus, len(hs.checks))
+	var wg sync.WaitGroup
+	for i, c := range hs.checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = checkStatus{Status: statusOK}
+			if err := c.check(ctx); err != nil {
+				results[i] = checkStatus{Status: statusError, Error: err.Error()}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	body := healthBody{Status: statusOK, Checks: make(map[string]checkStatus, len(hs.checks))}
+	status := http.StatusOK
+	for i, c := range hs.checks {
+		body.Checks[c.name] = results[i]
+		if results[i].Status != statusOK {
+			body.Status = statusUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if status != http.StatusOK {
+		hs.logger.WarnContext(r.Context(), "Сервис не готов", "checks", body.Checks)
+	}
+	writeHealth(w, status, body)
+}
+
+// Запись ответа проверки в формате JSON
+func writeHealth(w http.ResponseWriter, status int, body healthBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
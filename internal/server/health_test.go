@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wbl0/internal/config"
+	"wbl0/internal/logging"
+)
+
+func TestHTTPServer_Healthz(t *testing.T) {
+	handler := NewHTTPServer(nil, WithReadinessCheck("postgres", func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+
+	//Проверка жизнеспособности не зависит от проверок готовности
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, recorder.Body.String())
+}
+
+func TestHTTPServer_Readyz(t *testing.T) {
+	var dbErr error
+	handler := NewHTTPServer(nil,
+		WithReadinessCheck("postgres", func(context.Context) error { return dbErr }),
+		WithReadinessCheck("nats", func(context.Context) error { return nil }),
+	)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":{"postgres":{"status":"ok"},"nats":{"status":"ok"}}}`, recorder.Body.String())
+
+	//Недоступная зависимость делает сервис неготовым, причина возвращается в теле ответа
+	dbErr = errors.New("connection refused")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var body healthBody
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+	assert.Equal(t, statusUnavailable, body.Status)
+	assert.Equal(t, checkStatus{Status: statusError, Error: "connection refused"}, body.Checks["postgres"])
+	assert.Equal(t, statusOK, body.Checks["nats"].Status)
+}
+
+func TestHTTPServer_ReadyzNotLoggedAsError(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHTTPServer(nil,
+		WithLogger(logging.New(config.Log{Level: "info", Format: "json"}, &buf)),
+		WithReadinessCheck("cache", func(context.Context) error { return errors.New("кэш еще не загружен") }),
+	)
+
+	//Неготовность во время загрузки кэша не записывается в журнал на уровне error
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.NotContains(t, buf.String(), `"level":"ERROR"`)
+	assert.NotContains(t, buf.String(), `"msg":"HTTP-запрос"`)
+}
@@ -1,30 +1,143 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"wbl0/internal/broker"
+	"wbl0/internal/config"
+	"wbl0/internal/logging"
+	"wbl0/internal/model"
 	"wbl0/internal/service"
 )
 
+// Хранилище отклоненных брокером сообщений (реализуется broker.DeadLetterStore)
+type DeadLetterStore interface {
+	List() []broker.DeadLetter
+	Replay(id uint64) error
+}
+
+// Заголовок с идентификатором корреляции запроса
+const headerRequestID = "X-Request-Id"
+
+// Максимальная длина идентификатора корреляции, принимаемого от клиента
+const maxRequestIDLength = 64
+
 // HTTP-сервер для обработки запросов
 type HTTPServer struct {
-	srv *service.Service
+	srv         *service.Service
+	deadLetters DeadLetterStore
+	checks      []namedCheck
+	logger      *slog.Logger
+}
+
+// Дополнительная настройка HTTP-сервера
+type Option func(hs *HTTPServer)
+
+// Подключение хранилища отклоненных сообщений для просмотра и повторной отправки через HTTP
+func WithDeadLetters(store DeadLetterStore) Option {
+	return func(hs *HTTPServer) {
+		hs.deadLetters = store
+	}
+}
+
+// Журнал HTTP-сервера. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(hs *HTTPServer) {
+		hs.logger = logger
+	}
 }
 
 // Создание нового HTTP-сервера c заданных сервисом
-func NewHTTPServer(srv *service.Service) *HTTPServer {
-	return &HTTPServer{srv: srv}
+func NewHTTPServer(srv *service.Service, opts ...Option) *HTTPServer {
+	hs := &HTTPServer{srv: srv, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(hs)
+	}
+	return hs
+}
+
+// Создание http.Server с заданными в конфигурации адресом и таймаутами
+func NewServer(cfg config.HTTP, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
 }
 
-// Обработка входящего HTTP-запроса и его маршрутизация в соответствии с URL
+// Обработка входящего HTTP-запроса с учетом его кода ответа и длительности в метриках и журнале.
+// Идентификатор корреляции берется из заголовка X-Request-Id или создается заново и возвращается в том же заголовке.
 func (hs *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	id := r.Header.Get(headerRequestID)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = logging.NewCorrelationID()
+	}
+	w.Header().Set(headerRequestID, id)
+	r = r.WithContext(logging.WithCorrelationID(r.Context(), id))
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	hs.route(rec, r)
+	observeRequest(r, rec.status, start)
+
+	level := slog.LevelInfo
+	switch {
+	case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+		//Проверки Kubernetes выполняются часто и не нужны в журнале на уровне info.
+		//Ответ 503 во время загрузки кэша - штатное состояние, а не ошибка сервера
+		level = slog.LevelDebug
+	case rec.status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	}
+	hs.logger.LogAttrs(r.Context(), level, "HTTP-запрос",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rec.status),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// Маршрутизация запроса в соответствии с URL
+func (hs *HTTPServer) route(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/":
 		hs.handleIndexPage(w, r)
 	case "/get_data":
 		hs.handleGetDataById(w, r)
+	case "/orders":
+		hs.handleListOrders(w, r)
+	case "/orders/search":
+		hs.handleSearchOrders(w, r)
+	case "/dead_letters":
+		hs.handleListDeadLetters(w, r)
+	case "/dead_letters/replay":
+		hs.handleReplayDeadLetter(w, r)
+	case "/healthz":
+		hs.handleHealthz(w, r)
+	case "/readyz":
+		hs.handleReadyz(w, r)
+	case "/metrics":
+		metricsHandler.ServeHTTP(w, r)
 	default:
+		if strings.HasPrefix(r.URL.Path, apiV1Prefix+"/") {
+			hs.serveAPIv1(w, r)
+			return
+		}
+		if id, ok := orderHistoryID(r.URL.Path); ok {
+			hs.handleOrderHistory(w, r, id)
+			return
+		}
 		http.NotFound(w, r)
 	}
 }
@@ -33,26 +146,233 @@ func (hs *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (hs *HTTPServer) handleIndexPage(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.ParseFiles("templates/index.html")
 	if err != nil {
+		hs.logger.ErrorContext(r.Context(), "Ошибка при загрузке шаблона", "error", err)
 		http.Error(w, "Ошибка при загрузке шаблона", 500)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	err = tmpl.Execute(w, nil)
 	if err != nil {
+		hs.logger.ErrorContext(r.Context(), "Ошибка при выполнении шаблона", "error", err)
 		http.Error(w, "Ошибка при выполнении шаблона", 500)
 		return
 	}
 }
 
-// Обработка запроса на получение данныхзаказа по идентификатору. Результат возвращается в формате JSON
+// Обработка запроса на получение данных заказа по идентификатору. Результат возвращается в формате JSON
+// или protobuf при заголовке Accept: application/x-protobuf.
+// При ошибке возвращается JSON с кодом и описанием ошибки: 400 для пустого или некорректного id, 404 для неизвестного заказа.
 func (hs *HTTPServer) handleGetDataById(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	data, err := hs.srv.GetDataById(id)
 	if err != nil {
-		http.Error(w, "Ошибка при получении данных", 500)
+		hs.writeServiceError(w, r, err)
+		return
+	}
+
+	writeOrderData(w, r, data)
+}
+
+// Размер страницы списка заказов по умолчанию и максимальный размер страницы
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Страница списка заказов
+type ordersPage struct {
+	Orders     []model.OrderInfo `json:"orders"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// Обработка запроса на получение страницы списка заказов.
+// Параметр limit задает размер страницы, параметр cursor - курсор next_cursor из предыдущего ответа.
+func (hs *HTTPServer) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	after, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	orders, next, err := hs.srv.ListOrders(after, limit)
+	if err != nil {
+		hs.writeServiceError(w, r, err)
+		return
+	}
+
+	writeOrdersPage(w, r, orders, next)
+}
+
+// Обработка запроса на поиск заказов.
+// Условия поиска: customer_id, track_number, delivery_service, rid, nm_id и диапазон даты создания from/to в формате RFC 3339.
+// Должно быть задано хотя бы одно условие. Результат разбивается на страницы так же, как в /orders.
+func (hs *HTTPServer) handleSearchOrders(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	after, limit, err := parsePage(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	orders, next, err := hs.srv.SearchOrders(filter, after, limit)
+	if err != nil {
+		hs.writeServiceError(w, r, err)
+		return
+	}
+
+	writeOrdersPage(w, r, orders, next)
+}
+
+// Разбор параметров страницы: limit и cursor
+func parsePage(query url.Values) (string, int, error) {
+	limit := defaultPageSize
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			return "", 0, errors.New("Некорректный размер страницы")
+		}
+		limit = n
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(query.Get("cursor"))
+	if err != nil {
+		return "", 0, errors.New("Некорректный курсор")
+	}
+
+	return string(after), limit, nil
+}
+
+// Разбор условий поиска заказов
+func parseOrderFilter(query url.Values) (model.OrderFilter, error) {
+	filter := model.OrderFilter{
+		CustomerId:      query.Get("customer_id"),
+		TrackNumber:     query.Get("track_number"),
+		DeliveryService: query.Get("delivery_service"),
+		Rid:             query.Get("rid"),
+	}
+
+	if raw := query.Get("nm_id"); raw != "" {
+		nmId, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return model.OrderFilter{}, errors.New("Некорректный nm_id")
+		}
+		filter.NmId = nmId
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.CreatedFrom}, {"to", &filter.CreatedTo}} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return model.OrderFilter{}, fmt.Errorf("Некорректная дата %s", p.name)
+		}
+		*p.dst = t
+	}
+
+	if filter.IsEmpty() {
+		return model.OrderFilter{}, errors.New("Не задано ни одного условия поиска")
+	}
+	return filter, nil
+}
+
+// Запись страницы заказов в ответ в формате JSON или protobuf
+func writeOrdersPage(w http.ResponseWriter, r *http.Request, orders []model.OrderInfo, next string) {
+	page := ordersPage{Orders: orders}
+	if page.Orders == nil {
+		page.Orders = []model.OrderInfo{}
+	}
+	if next != "" {
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+
+	writeOrderData(w, r, page)
+}
+
+// Обработка запроса на получение списка отклоненных брокером сообщений. Результат возвращается в формате JSON
+func (hs *HTTPServer) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if hs.deadLetters == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hs.deadLetters.List())
+}
+
+// Обработка запроса на повторную отправку отклоненного сообщения в канал заказов
+func (hs *HTTPServer) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if hs.deadLetters == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Некорректный идентификатор сообщения")
+		return
+	}
+
+	err = hs.deadLetters.Replay(id)
+	if errors.Is(err, broker.ErrDeadLetterNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Сообщение не найдено")
+		return
+	}
+	if err != nil {
+		hs.logger.ErrorContext(r.Context(), "Ошибка при повторной отправке сообщения", "id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Ошибка при повторной отправке сообщения")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Идентификатор заказа из пути вида /orders/{id}/history
+func orderHistoryID(path string) (string, bool) {
+	id, ok := strings.CutPrefix(path, "/orders/")
+	if !ok {
+		return "", false
+	}
+	id, ok = strings.CutSuffix(id, "/history")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// Обработка запроса на получение истории получения заказа из журнала аудита.
+// Для каждого сообщения с заказом возвращаются канал, номер в JetStream, время получения, хэш содержимого и результат сохранения.
+func (hs *HTTPServer) handleOrderHistory(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	history, err := hs.srv.OrderHistory(id)
+	if err != nil {
+		hs.writeServiceError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+	json.NewEncoder(w).Encode(history)
 }
@@ -1,12 +1,21 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+	"wbl0/internal/broker"
 	"wbl0/internal/cache"
+	"wbl0/internal/config"
 	"wbl0/internal/database"
+	"wbl0/internal/logging"
+	"wbl0/internal/model"
 	"wbl0/internal/service"
 	"wbl0/testutils"
 )
@@ -23,7 +32,7 @@ func TestHTTPServer_GetOrder(t *testing.T) {
 	handler := NewHTTPServer(s)
 
 	//Создание тестового заказа и сохранение его в БД и кэш
-	err := s.SaveData(testutils.TestOrder)
+	err := s.SaveData(context.Background(), testutils.TestOrder, model.Source{})
 	assert.NoError(t, err)
 
 	//Создание тестового http-запроса
@@ -40,3 +49,232 @@ func TestHTTPServer_GetOrder(t *testing.T) {
 	//Проверка кода ответа
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
+
+// Хранилище отклоненных сообщений для тестов
+type fakeDeadLetters struct {
+	letters  []broker.DeadLetter
+	replayed []uint64
+}
+
+func (f *fakeDeadLetters) List() []broker.DeadLetter {
+	return f.letters
+}
+
+func (f *fakeDeadLetters) Replay(id uint64) error {
+	for _, l := range f.letters {
+		if l.ID == id {
+			f.replayed = append(f.replayed, id)
+			return nil
+		}
+	}
+	return broker.ErrDeadLetterNotFound
+}
+
+func TestHTTPServer_DeadLetters(t *testing.T) {
+	store := &fakeDeadLetters{letters: []broker.DeadLetter{{ID: 1, Stage: "decode", Payload: "{not json"}}}
+	handler := NewHTTPServer(nil, WithDeadLetters(store))
+
+	//Получение списка отклоненных сообщений
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/dead_letters", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var letters []broker.DeadLetter
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&letters))
+	assert.Equal(t, "{not json", letters[0].Payload)
+
+	//Повторная отправка существующего и отсутствующего сообщений
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/dead_letters/replay?id=1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, []uint64{1}, store.replayed)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/dead_letters/replay?id=2", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/dead_letters/replay?id=1", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestHTTPServer_ListOrders(t *testing.T) {
+	// Подготовка к тестированию HTTP-сервера
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	s := service.NewService(database.NewDB(db), cache.NewCache())
+	handler := NewHTTPServer(s)
+
+	//Сохранение трех заказов в БД
+	for _, uid := range []string{"c", "a", "b"} {
+		order := testutils.TestOrder
+		order.OrderUid = uid
+		assert.NoError(t, s.SaveData(context.Background(), order, model.Source{}))
+	}
+
+	//Получение страниц по два заказа с переходом по курсору
+	var uids []string
+	url := "/orders?limit=2"
+	for pages := 0; pages < 3; pages++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var page ordersPage
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&page))
+		for _, order := range page.Orders {
+			uids = append(uids, order.OrderUid)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		url = "/orders?limit=2&cursor=" + page.NextCursor
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, uids)
+}
+
+func TestHTTPServer_ListOrdersInvalidParams(t *testing.T) {
+	handler := NewHTTPServer(nil)
+
+	for _, url := range []string{"/orders?limit=0", "/orders?limit=501", "/orders?limit=x", "/orders?cursor=!!"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, url)
+	}
+}
+
+func TestHTTPServer_SearchOrders(t *testing.T) {
+	// Подготовка к тестированию HTTP-сервера
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	s := service.NewService(database.NewDB(db), cache.NewCache())
+	handler := NewHTTPServer(s)
+
+	//Сохранение заказов разных покупателей
+	other := testutils.TestOrder
+	other.OrderUid = "other"
+	other.CustomerId = "other-customer"
+	other.DateCreated = time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	other.Items = []model.Item{testutils.TestOrder.Items[0]}
+	other.Items[0].Rid = "other-rid"
+	assert.NoError(t, s.SaveData(context.Background(), testutils.TestOrder, model.Source{}))
+	assert.NoError(t, s.SaveData(context.Background(), other, model.Source{}))
+
+	cases := map[string][]string{
+		"/orders/search?customer_id=test":                                         {testutils.TestOrder.OrderUid},
+		"/orders/search?track_number=WBILMTESTTRACK":                              {testutils.TestOrder.OrderUid, other.OrderUid},
+		"/orders/search?delivery_service=meest&customer_id=other-customer":        {other.OrderUid},
+		"/orders/search?rid=other-rid":                                            {other.OrderUid},
+		"/orders/search?nm_id=2389212":                                            {testutils.TestOrder.OrderUid, other.OrderUid},
+		"/orders/search?from=2023-01-01T00:00:00Z":                                {other.OrderUid},
+		"/orders/search?from=2021-01-01T00:00:00Z&to=2022-01-01T03:00:00%2B03:00": {testutils.TestOrder.OrderUid},
+		"/orders/search?customer_id=nobody":                                       {},
+	}
+	for url, expected := range cases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, url)
+
+		var page ordersPage
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&page))
+		uids := []string{}
+		for _, order := range page.Orders {
+			uids = append(uids, order.OrderUid)
+		}
+		assert.ElementsMatch(t, expected, uids, url)
+	}
+}
+
+func TestHTTPServer_SearchOrdersInvalidParams(t *testing.T) {
+	handler := NewHTTPServer(nil)
+
+	for _, url := range []string{"/orders/search", "/orders/search?nm_id=x", "/orders/search?from=yesterday", "/orders/search?customer_id=test&limit=0"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, url)
+	}
+}
+
+func TestHTTPServer_GetOrderErrors(t *testing.T) {
+	// Подготовка к тестированию HTTP-сервера
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	handler := NewHTTPServer(service.NewService(database.NewDB(db), cache.NewCache()))
+
+	cases := []struct {
+		url    string
+		status int
+		code   string
+	}{
+		{"/get_data", http.StatusBadRequest, codeInvalidID},
+		{"/get_data?id=%20", http.StatusBadRequest, codeInvalidID},
+		{"/get_data?id=" + strings.Repeat("a", 51), http.StatusBadRequest, codeInvalidID},
+		{"/get_data?id=unknown", http.StatusNotFound, codeNotFound},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", c.url, nil))
+		assert.Equal(t, c.status, recorder.Code, c.url)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+		var body errorBody
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+		assert.Equal(t, c.code, body.Code, c.url)
+		assert.NotEmpty(t, body.Message)
+	}
+}
+
+func TestHTTPServer_RequestLog(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHTTPServer(nil, WithLogger(logging.New(config.Log{Level: "info", Format: "json"}, &buf)))
+
+	//Идентификатор корреляции из запроса возвращается в ответе и записывается в журнал
+	rq := httptest.NewRequest("GET", "/api/v1/unknown", nil)
+	rq.Header.Set("X-Request-Id", "req-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, rq)
+	assert.Equal(t, "req-1", recorder.Header().Get("X-Request-Id"))
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "req-1", record[logging.CorrelationIDKey])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+
+	//Без заголовка идентификатор создается сервером
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/unknown", nil))
+	assert.NotEmpty(t, recorder.Header().Get("X-Request-Id"))
+}
+
+func TestHTTPServer_OrderHistory(t *testing.T) {
+	// Подготовка к тестированию HTTP-сервера
+	db := testutils.InitTestDatabase(t)
+	defer db.Close()
+	s := service.NewService(database.NewDB(db), cache.NewCache())
+	handler := NewHTTPServer(s)
+
+	//Заказ получен дважды: второе сообщение пропускается, но тоже записывается в журнал аудита
+	src := model.Source{Subject: broker.OrderSubject, Sequence: 7, ReceivedAt: time.Now(), PayloadHash: "abc"}
+	assert.NoError(t, s.SaveData(context.Background(), testutils.TestOrder, src))
+	src.Sequence = 8
+	assert.NoError(t, s.SaveData(context.Background(), testutils.TestOrder, src))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/orders/"+testutils.TestOrder.OrderUid+"/history", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var history []model.AuditEntry
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&history))
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, model.AuditInserted, history[0].Action)
+		assert.Equal(t, uint64(7), history[0].Sequence)
+		assert.Equal(t, broker.OrderSubject, history[0].Subject)
+		assert.Equal(t, model.AuditSkipped, history[1].Action)
+	}
+
+	//Неизвестный заказ
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/orders/unknown/history", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wbl0_http_requests_total",
+		Help: "Количество HTTP-запросов по маршруту, методу и коду ответа",
+	}, []string{"route", "method", "code"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wbl0_http_request_duration_seconds",
+		Help:    "Длительность обработки HTTP-запросов по маршруту",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// Обработчик /metrics с метриками из реестра Prometheus по умолчанию
+var metricsHandler = promhttp.Handler()
+
+// Маршруты, которые учитываются в метриках по точному совпадению пути
+var metricRoutes = map[string]bool{
+	"/":                                      true,
+	"/get_data":                              true,
+	"/orders":                                true,
+	"/orders/search":                         true,
+	"/dead_letters":                          true,
+	"/dead_letters/replay":                   true,
+	"/metrics":                               true,
+	"/healthz":                               true,
+	"/readyz":                                true,
+	apiV1Prefix + "/openapi.json":            true,
+	apiV1Prefix + "/schemas/order_info.json": true,
+	apiV1Prefix + "/orders":                  true,
+	apiV1Prefix + "/orders/search":           true,
+	apiV1Prefix + "/orders/{id}/items":       true,
+	apiV1Prefix + "/orders/{id}/delivery":    true,
+	apiV1Prefix + "/orders/{id}/payment":     true,
+	apiV1Prefix + "/orders/{id}/history":     true,
+}
+
+// ResponseWriter, запоминающий код ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Учет HTTP-запроса в метриках
+func observeRequest(r *http.Request, status int, start time.Time) {
+	route := routeLabel(r.URL.Path)
+	httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+}
+
+// Шаблон маршрута для метрик. Идентификаторы заказов заменяются на {id},
+// неизвестные пути объединяются в один маршрут, чтобы количество серий метрик было ограничено.
+func routeLabel(path string) string {
+	if metricRoutes[path] {
+		return path
+	}
+
+	if _, ok := orderHistoryID(path); ok {
+		return "/orders/{id}/history"
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, apiV1Prefix+"/"), "/")
+	if strings.HasPrefix(path, apiV1Prefix+"/orders/") && (len(parts) == 2 || len(parts) == 3) {
+		parts[1] = "{id}"
+		route := apiV1Prefix + "/" + strings.Join(parts, "/")
+		if len(parts) == 2 || metricRoutes[route] {
+			return route
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteLabel(t *testing.T) {
+	cases := map[string]string{
+		"/get_data":                            "/get_data",
+		"/healthz":                             "/healthz",
+		"/readyz":                              "/readyz",
+		"/api/v1/orders":                       "/api/v1/orders",
+		"/api/v1/orders/b563feb7b2b84b6":       "/api/v1/orders/{id}",
+		"/api/v1/orders/b563feb7b2b84b6/items": "/api/v1/orders/{id}/items",
+		"/api/v1/orders/b563feb7b2b84b6/other": "other",
+		"/orders/b563feb7b2b84b6/history":      "/orders/{id}/history",
+		"/favicon.ico":                         "other",
+	}
+	for path, route := range cases {
+		assert.Equal(t, route, routeLabel(path), path)
+	}
+}
+
+func TestHTTPServer_Metrics(t *testing.T) {
+	handler := NewHTTPServer(nil)
+
+	//Запрос к неизвестному ресурсу учитывается в метриках
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `wbl0_http_requests_total{code="404",method="GET",route="other"}`)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"wbl0/internal/model"
+	"wbl0/internal/schema"
+)
+
+// Описание REST API версии 1 в формате OpenAPI 3.1.
+// Схемы данных генерируются по структурам model и ответов сервера, поэтому документ не расходится с кодом.
+var openAPIDocument = sync.OnceValue(func() []byte {
+	g := schema.NewGenerator("#/components/schemas/")
+	order := g.Schema(reflect.TypeOf(model.OrderInfo{}))
+	delivery := g.Schema(reflect.TypeOf(model.Delivery{}))
+	payment := g.Schema(reflect.TypeOf(model.Payment{}))
+	item := g.Schema(reflect.TypeOf(model.Item{}))
+	audit := g.Schema(reflect.TypeOf(model.AuditEntry{}))
+	page := g.Define("OrdersPage", reflect.TypeOf(ordersPage{}))
+	errSchema := g.Define("Error", reflect.TypeOf(errorBody{}))
+
+	minPage, maxPage := float64(1), float64(maxPageSize)
+	pageParams := []any{
+		queryParam("limit", "Размер страницы", &schema.Schema{Type: "integer", Format: "int32", Minimum: &minPage, Maximum: &maxPage}),
+		queryParam("cursor", "Курсор next_cursor из предыдущего ответа", &schema.Schema{Type: "string"}),
+	}
+	idParam := map[string]any{
+		"name":        "id",
+		"in":          "path",
+		"required":    true,
+		"description": "Идентификатор заказа (order_uid)",
+		"schema":      &schema.Schema{Type: "string"},
+	}
+	orderPart := func(summary string, ok map[string]any) map[string]any {
+		return map[string]any{
+			"get": map[string]any{
+				"summary":    summary,
+				"parameters": []any{idParam},
+				"responses": map[string]any{
+					"200": ok,
+					"400": jsonResponse("Некорректный идентификатор заказа", errSchema),
+					"404": jsonResponse("Заказ не найден", errSchema),
+					"500": jsonResponse("Внутренняя ошибка", errSchema),
+				},
+			},
+		}
+	}
+
+	searchParams := []any{
+		queryParam("customer_id", "Идентификатор покупателя", &schema.Schema{Type: "string"}),
+		queryParam("track_number", "Трек-номер заказа", &schema.Schema{Type: "string"}),
+		queryParam("delivery_service", "Служба доставки", &schema.Schema{Type: "string"}),
+		queryParam("rid", "Идентификатор товара в заказе", &schema.Schema{Type: "string"}),
+		queryParam("nm_id", "Артикул товара", &schema.Schema{Type: "integer", Format: "int64"}),
+		queryParam("from", "Начало диапазона даты создания (включительно)", &schema.Schema{Type: "string", Format: "date-time"}),
+		queryParam("to", "Конец диапазона даты создания (не включительно)", &schema.Schema{Type: "string", Format: "date-time"}),
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "wbl0 orders API",
+			"version": "1.0.0",
+		},
+		"servers": []any{map[string]any{"url": apiV1Prefix}},
+		"paths": map[string]any{
+			"/orders": map[string]any{
+				"get": map[string]any{
+					"summary":    "Список заказов с keyset-пагинацией по order_uid",
+					"parameters": pageParams,
+					"responses": map[string]any{
+						"200": orderDataResponse("Страница заказов", page, "OrdersPage"),
+						"400": jsonResponse("Некорректные параметры страницы", errSchema),
+						"500": jsonResponse("Внутренняя ошибка", errSchema),
+					},
+				},
+			},
+			"/orders/search": map[string]any{
+				"get": map[string]any{
+					"summary":    "Поиск заказов, должно быть задано хотя бы одно условие",
+					"parameters": append(searchParams, pageParams...),
+					"responses": map[string]any{
+						"200": orderDataResponse("Страница найденных заказов", page, "OrdersPage"),
+						"400": jsonResponse("Некорректные условия поиска", errSchema),
+						"500": jsonResponse("Внутренняя ошибка", errSchema),
+					},
+				},
+			},
+			"/orders/{id}":          orderPart("Заказ", orderDataResponse("Успешный ответ", order, "OrderInfo")),
+			"/orders/{id}/items":    orderPart("Товары заказа", orderDataResponse("Успешный ответ", &schema.Schema{Type: "array", Items: item}, "ItemList")),
+			"/orders/{id}/delivery": orderPart("Доставка заказа", orderDataResponse("Успешный ответ", delivery, "Delivery")),
+			"/orders/{id}/payment":  orderPart("Оплата заказа", orderDataResponse("Успешный ответ", payment, "Payment")),
+			"/orders/{id}/history":  orderPart("История получения заказа из журнала аудита", jsonResponse("Успешный ответ", &schema.Schema{Type: "array", Items: audit})),
+			"/schemas/order_info.json": map[string]any{
+				"get": map[string]any{
+					"summary": "JSON Schema сообщения с заказом, которое принимает брокер",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "JSON Schema 2020-12",
+							"content": map[string]any{
+								"application/schema+json": map[string]any{"schema": &schema.Schema{Type: "object"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": g.Defs,
+		},
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return data
+})
+
+// Описание параметра запроса
+func queryParam(name, description string, s *schema.Schema) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      s,
+	}
+}
+
+// Описание ответа в формате JSON
+func jsonResponse(description string, s *schema.Schema) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": s},
+		},
+	}
+}
+
+// Описание ответа с данными заказа в формате JSON или protobuf.
+// message - имя сообщения из order.proto, которое возвращается при заголовке Accept: application/x-protobuf
+func orderDataResponse(description string, s *schema.Schema, message string) map[string]any {
+	resp := jsonResponse(description, s)
+	resp["content"].(map[string]any)[contentTypeProtobuf] = map[string]any{
+		"schema": map[string]any{
+			"description": "Сообщение wbl0.order.v1." + message,
+		},
+	}
+	return resp
+}
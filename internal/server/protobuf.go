@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"wbl0/internal/model"
+	"wbl0/internal/model/orderpb"
+)
+
+// Формат ответа protobuf, запрашиваемый заголовком Accept
+const contentTypeProtobuf = "application/x-protobuf"
+
+// Проверка того, что клиент предпочитает ответ в формате protobuf, а не JSON.
+// application/protobuf считается синонимом application/x-protobuf. Выбирается формат с большим весом q,
+// при равном весе - указанный раньше. Вес JSON, если он не указан явно, берется из application/* или */*
+func acceptsProtobuf(r *http.Request) bool {
+	protoQ, jsonQ, wildcardQ := -1.0, -1.0, -1.0
+	var protoPos, jsonPos, wildcardPos, pos int
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			pos++
+			q := 1.0
+			if raw, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(raw, 64); err != nil {
+					continue
+				}
+			}
+			switch mt {
+			case contentTypeProtobuf, "application/protobuf":
+				if q > protoQ {
+					protoQ, protoPos = q, pos
+				}
+			case "application/json":
+				if q > jsonQ {
+					jsonQ, jsonPos = q, pos
+				}
+			case "application/*", "*/*":
+				if q > wildcardQ {
+					wildcardQ, wildcardPos = q, pos
+				}
+			}
+		}
+	}
+	if jsonQ < 0 {
+		jsonQ, jsonPos = wildcardQ, wildcardPos
+	}
+	//Формат с нулевым весом q клиентом не принимается
+	return protoQ > 0 && (protoQ > jsonQ || protoQ == jsonQ && protoPos < jsonPos)
+}
+
+// Сообщение protobuf для данных заказа из ответа. Для остальных данных возвращается false
+func protoMessage(v any) (proto.Message, bool) {
+	switch v := v.(type) {
+	case model.OrderInfo:
+		return v.ToProto(), true
+	case model.Delivery:
+		return v.ToProto(), true
+	case model.Payment:
+		return v.ToProto(), true
+	case []model.Item:
+		return &orderpb.ItemList{Items: model.ItemsToProto(v)}, true
+	case ordersPage:
+		orders := make([]*orderpb.OrderInfo, len(v.Orders))
+		for i, order := range v.Orders {
+			orders[i] = order.ToProto()
+		}
+		return &orderpb.OrdersPage{Orders: orders, NextCursor: v.NextCursor}, true
+	}
+	return nil, false
+}
+
+// Запись данных заказа в ответ в формате protobuf, если он запрошен заголовком Accept, иначе в формате JSON
+func writeOrderData(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Add("Vary", "Accept")
+	if acceptsProtobuf(r) {
+		if m, ok := protoMessage(v); ok {
+			data, err := proto.Marshal(m)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, codeInternal, "Ошибка при кодировании ответа")
+				return
+			}
+			w.Header().Set("Content-Type", contentTypeProtobuf)
+			w.Write(data)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -1,23 +1,60 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
 	"wbl0/internal/cache"
 	"wbl0/internal/database"
 	"wbl0/internal/model"
 )
 
+// Максимальная длина идентификатора заказа (order_uid VARCHAR(50))
+const maxIDLength = 50
+
+var (
+	//Идентификатор заказа пустой или не может существовать в БД
+	ErrInvalidID = errors.New("некорректный идентификатор заказа")
+	//Размер страницы заказов меньше одного
+	ErrInvalidLimit = errors.New("некорректный размер страницы")
+	//Заказ с указанным идентификатором не найден
+	ErrNotFound = database.ErrNotFound
+	//Заказ уже сохранен, а политика сохранения повторных заказов запрещает его повторное сохранение
+	ErrDuplicate = database.ErrDuplicate
+	//Новый статус недопустим для текущего статуса товара
+	ErrInvalidTransition = model.ErrInvalidTransition
+)
+
 // Сервис для работы с данными заказов
 type Service struct {
-	db    *database.PostgresDB
-	cache *cache.Cache
+	db     *database.PostgresDB
+	cache  cache.OrderCache
+	logger *slog.Logger
+}
+
+// Дополнительная настройка сервиса
+type Option func(s *Service)
+
+// Журнал сервиса. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
 }
 
 // Создание нового экземпляра сервиса и инициализация его зависимостей
-func NewService(db *database.PostgresDB, cache *cache.Cache) *Service {
-	return &Service{
-		db:    db,
-		cache: cache,
+func NewService(db *database.PostgresDB, cache cache.OrderCache, opts ...Option) *Service {
+	s := &Service{
+		db:     db,
+		cache:  cache,
+		logger: slog.Default(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Получение всех данных заказов из БД
@@ -25,10 +62,56 @@ func (s *Service) GetAllDataFromDB() ([]model.OrderInfo, error) {
 	return s.db.GetAllData()
 }
 
+// Потоковое чтение всех заказов из БД. Каждый заказ передается в fn сразу после чтения
+func (s *Service) ForEachOrder(fn func(order model.OrderInfo) error) error {
+	return s.db.ForEachOrder(fn)
+}
+
+// Потоковое чтение из БД заказов, измененных не раньше watermark
+func (s *Service) ForEachOrderUpdatedAfter(watermark time.Time, fn func(order model.OrderInfo) error) error {
+	return s.db.ForEachOrderUpdatedAfter(watermark, fn)
+}
+
+// Водяной знак для снимка кэша по часам БД
+func (s *Service) Watermark() (time.Time, error) {
+	return s.db.Watermark()
+}
+
+// Получение страницы заказов, отсортированных по order_uid, начиная после заказа after.
+// Вторым значением возвращается order_uid, после которого начинается следующая страница,
+// или пустая строка, если страница последняя. Если limit меньше одного, возвращается ErrInvalidLimit.
+func (s *Service) ListOrders(after string, limit int) ([]model.OrderInfo, string, error) {
+	return s.SearchOrders(model.OrderFilter{}, after, limit)
+}
+
+// Поиск заказов по условиям filter с разбиением на страницы так же, как в ListOrders
+func (s *Service) SearchOrders(filter model.OrderFilter, after string, limit int) ([]model.OrderInfo, string, error) {
+	if limit < 1 {
+		return nil, "", ErrInvalidLimit
+	}
+
+	//Запрашивается на один заказ больше, чтобы узнать, есть ли следующая страница
+	orders, err := s.db.SearchOrders(filter, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(orders) <= limit {
+		return orders, "", nil
+	}
+
+	orders = orders[:limit]
+	return orders, orders[limit-1].OrderUid, nil
+}
+
 // Получение данных заказа по идентификатору.
 // Сначала поиск производится в кэше, если данные там есть - возвращаем их.
-// Если данных нет в кэше, производится обращение к БД, полученные данные сохраняются в кэше и возвращаются
+// Если данных нет в кэше, производится обращение к БД, полученные данные сохраняются в кэше и возвращаются.
+// Для пустого или слишком длинного идентификатора возвращается ErrInvalidID, для отсутствующего заказа - ErrNotFound.
 func (s *Service) GetDataById(id string) (model.OrderInfo, error) {
+	if !validID(id) {
+		return model.OrderInfo{}, ErrInvalidID
+	}
+
 	data, ok := s.cache.GetById(id)
 	if ok {
 		return data, nil
@@ -43,19 +126,87 @@ func (s *Service) GetDataById(id string) (model.OrderInfo, error) {
 	return data, nil
 }
 
-// Сохранение данных заказа.
-// Сначала проверяется наличие данных в кэше. Если есть, они повторно не сохраняются.
-// Если данных нет в кэше, производится сохранение этих данных в БД и обновление кэша.
-func (s *Service) SaveData(data model.OrderInfo) error {
-	_, ok := s.cache.GetById(data.OrderUid)
-	if ok {
-		//Данные уже есть в кэше, повторно их сохранять не нужно
-		return nil
-	}
-	if err := s.db.SaveData(data); err != nil {
+// Сохранение данных заказа, полученного из источника src, в БД и обновление кэша.
+// Повторные заказы обрабатываются в БД по политике, заданной в database.WithDuplicatePolicy:
+// при политике reject возвращается ошибка ErrDuplicate, а пропущенный заказ не попадает в кэш.
+// ctx передает в журнал идентификатор корреляции сообщения.
+func (s *Service) SaveData(ctx context.Context, data model.OrderInfo, src model.Source) error {
+	result, err := s.db.SaveData(data, src)
+	if err != nil {
 		return err
 	}
+	s.cacheSaved(ctx, data, result)
+	return nil
+}
+
+// Обновление кэша после сохранения заказа data с результатом result.
+// Замененный заказ перечитывается из БД, потому что статусы его товаров сохраняются из предыдущей версии.
+// Заказ уже сохранен, поэтому ошибка чтения только записывается в журнал, а в кэш попадает полученная версия.
+func (s *Service) cacheSaved(ctx context.Context, data model.OrderInfo, result database.SaveResult) {
+	if result == database.Skipped {
+		s.logger.InfoContext(ctx, "Повторный заказ пропущен", "order_uid", data.OrderUid)
+		return
+	}
+	if result == database.Updated {
+		if saved, err := s.db.GetDataById(data.OrderUid); err == nil {
+			data = saved
+		} else {
+			s.logger.WarnContext(ctx, "Ошибка при чтении замененного заказа из БД", "order_uid", data.OrderUid, "error", err)
+		}
+	}
 
 	s.cache.SetById(data.OrderUid, data)
+	s.logger.DebugContext(ctx, "Заказ сохранен в БД и кэше", "order_uid", data.OrderUid, "updated", result == database.Updated)
+}
+
+// Сохранение пакета заказов в БД одной транзакцией и обновление кэша. srcs - источники заказов в порядке orders.
+// Возвращает ошибки сохранения отдельных заказов в порядке orders (nil для сохраненных и пропущенных заказов)
+// и ошибку, если пакет не удалось сохранить целиком. Повторные заказы обрабатываются так же, как в SaveData.
+func (s *Service) SaveBatch(ctx context.Context, orders []model.OrderInfo, srcs []model.Source) ([]error, error) {
+	results, err := s.db.SaveBatch(orders, srcs)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(orders))
+	for i, result := range results {
+		if result.Err != nil {
+			errs[i] = result.Err
+			continue
+		}
+		s.cacheSaved(ctx, orders[i], result.Result)
+	}
+	return errs, nil
+}
+
+// Изменение статуса товаров заказа.
+// Переход проверяется и записывается в историю в БД, после чего заказ перечитывается из БД в кэш.
+// Если переход недопустим, возвращается ошибка ErrInvalidTransition, если заказ или товар не найден - ErrNotFound.
+// Статус уже изменен, поэтому ошибка чтения заказа из БД только записывается в журнал, а в кэше остается прежняя версия:
+// при повторной доставке события переход был бы отклонен как недопустимый.
+func (s *Service) UpdateStatus(ctx context.Context, update model.StatusUpdate) error {
+	if err := s.db.UpdateStatus(update); err != nil {
+		return err
+	}
+
+	if data, err := s.db.GetDataById(update.OrderUid); err == nil {
+		s.cache.SetById(update.OrderUid, data)
+	} else {
+		s.logger.WarnContext(ctx, "Ошибка при чтении заказа из БД после изменения статуса", "order_uid", update.OrderUid, "error", err)
+	}
+	s.logger.InfoContext(ctx, "Статус заказа изменен", "order_uid", update.OrderUid, "chrt_id", update.ChrtId, "status", update.Status)
 	return nil
 }
+
+// История получения заказа из журнала аудита
+func (s *Service) OrderHistory(id string) ([]model.AuditEntry, error) {
+	if !validID(id) {
+		return nil, ErrInvalidID
+	}
+	return s.db.OrderHistory(id)
+}
+
+// Проверка того, что идентификатор заказа непустой и может существовать в БД
+func validID(id string) bool {
+	return strings.TrimSpace(id) != "" && len(id) <= maxIDLength
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"wbl0/internal/cache"
+	"wbl0/internal/model"
+)
+
+func TestService_SearchOrdersInvalidLimit(t *testing.T) {
+	//Размер страницы проверяется до обращения к БД
+	s := NewService(nil, cache.NewCache())
+	for _, limit := range []int{0, -1} {
+		_, _, err := s.ListOrders("", limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+		_, _, err = s.SearchOrders(model.OrderFilter{CustomerId: "test"}, "", limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	}
+}
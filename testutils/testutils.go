@@ -2,7 +2,11 @@ package testutils
 
 import (
 	"database/sql"
+	"github.com/nats-io/nats-server/v2/server"
 	"testing"
+	"time"
+	"wbl0/internal/config"
+	"wbl0/internal/database/migrations"
 	"wbl0/internal/model"
 )
 
@@ -53,25 +57,54 @@ var TestOrder = model.OrderInfo{
 	DeliveryService:   "meest",
 	Shardkey:          "9",
 	SmId:              99,
-	DateCreated:       "2021-11-26T06:22:19Z",
+	DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
 	OofShard:          "1",
 }
 
-// Вспомогательная функция для создания временной базы данных для тестирования
+// Вспомогательная функция для создания временной базы данных для тестирования.
+// Параметры подключения берутся из конфигурации (файл из WBL0_CONFIG и переменные окружения).
 func InitTestDatabase(t *testing.T) *sql.DB {
-	db, err := sql.Open("postgres", "host=localhost port=5432 user=postgres password=postgres dbname=wbl0 sslmode=disable")
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Ошибка при загрузке конфигурации: %v", err)
+	}
+	db, err := sql.Open("postgres", cfg.Postgres.DSN())
 	if err != nil {
 		t.Fatalf("Ошибка при создании временной базы данных: %v", err)
 	}
-	// Очистка и создание таблиц перед каждым тестом
-	_, err = db.Exec("DROP TABLE IF EXISTS order_info, deliveries, payments, items")
+	// Очистка таблиц и применение миграций перед каждым тестом
+	_, err = db.Exec("DROP TABLE IF EXISTS order_audit, order_status_history, items, payments, deliveries, order_info, schema_migrations")
 	if err != nil {
 		t.Fatalf("Ошибка при очистке таблиц: %v", err)
 	}
-	_, err = db.Exec("CREATE TABLE IF NOT EXISTS order_info (\n\torder_uid VARCHAR(50) PRIMARY KEY,\n\ttrack_number VARCHAR(50) NOT NULL,\n\tentry VARCHAR(50) NOT NULL,\n\tlocale VARCHAR(10) NOT NULL,\n\tinternal_signature VARCHAR(100),\n\tcustomer_id VARCHAR(50) NOT NULL,\n\tdelivery_service VARCHAR(50) NOT NULL,\n\tshardkey VARCHAR(50) NOT NULL,\n\tsm_id INT NOT NULL,\n\tdate_created VARCHAR(50) NOT NULL,\n\toof_shard VARCHAR(50) NOT NULL\n);\n\nCREATE TABLE IF NOT EXISTS deliveries (\n\torder_uid VARCHAR(50) PRIMARY KEY REFERENCES order_info(order_uid),\n\tname VARCHAR(100) NOT NULL,\n\tphone VARCHAR(20) NOT NULL,\n\tzip VARCHAR(10) NOT NULL,\n\tcity VARCHAR(100) NOT NULL,\n\taddress VARCHAR(100) NOT NULL,\n\tregion VARCHAR(100) NOT NULL,\n\temail VARCHAR(100) NOT NULL\n);\n\nCREATE TABLE IF NOT EXISTS payments (\n\torder_uid VARCHAR(50) PRIMARY KEY REFERENCES order_info(order_uid),\n\ttransaction VARCHAR(100) NOT NULL,\n\trequest_id VARCHAR(100),\n\tcurrency VARCHAR(10) NOT NULL,\n\tprovider VARCHAR(50) NOT NULL,\n\tamount INT NOT NULL,\n\tpayment_dt INT NOT NULL,\n\tbank VARCHAR(50) NOT NULL,\n\tdelivery_cost INT NOT NULL,\n\tgoods_total INT NOT NULL,\n\tcustom_fee INT\n);\n\nCREATE TABLE IF NOT EXISTS items (\n    order_uid VARCHAR(50) REFERENCES order_info(order_uid),\n\tchrt_id INT NOT NULL,\n\ttrack_number VARCHAR(50) NOT NULL,\n\tprice INT NOT NULL,\n\trid VARCHAR(100) NOT NULL,\n\tname VARCHAR(100) NOT NULL,\n\tsale INT NOT NULL,\n\tsize VARCHAR(50) NOT NULL,\n\ttotal_price INT NOT NULL,\n\tnm_id INT NOT NULL,\n\tbrand VARCHAR(100) NOT NULL,\n\tstatus INT NOT NULL,\n    PRIMARY KEY (order_uid, chrt_id)\n);")
-	if err != nil {
-		t.Fatalf("Ошибка при создании таблицы order_info: %v", err)
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("Ошибка при применении миграций: %v", err)
 	}
 
 	return db
 }
+
+// Вспомогательная функция для запуска встроенного сервера NATS с включенным JetStream.
+// Сервер останавливается автоматически по завершении теста.
+func RunNATSServer(t *testing.T) *server.Server {
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("Ошибка при создании сервера NATS: %v", err)
+	}
+
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("Сервер NATS не запустился")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	return ns
+}